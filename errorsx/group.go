@@ -0,0 +1,111 @@
+//spellchecker:words errorsx
+package errorsx
+
+//spellchecker:words context sync pkglib recovery sema
+import (
+	"context"
+	"sync"
+
+	"go.tkw01536.de/pkglib/recovery"
+	"go.tkw01536.de/pkglib/sema"
+)
+
+// Group runs functions concurrently and collects the errors they return.
+// It replaces manual combinations of [sync.WaitGroup], [sema.New] and [Combine].
+//
+// A Group must be created using [NewGroup].
+type Group struct {
+	ctx    context.Context //nolint:containedctx // context is shared between all functions of the group
+	cancel context.CancelCauseFunc
+
+	sema    sema.Semaphore // limits concurrent calls
+	collect bool           // collect all errors instead of only the first
+
+	wg sync.WaitGroup
+
+	m    sync.Mutex // protects errs
+	errs []error
+}
+
+// NewGroup creates a new group along with a context derived from ctx.
+//
+// concurrency.Limit determines the maximum number of functions running at the same time.
+// concurrency.Force enables the collect-all mode.
+//
+// By default, the first function to return a non-nil error cancels the derived context.
+// Functions that have not yet started when this happens are not called.
+// In collect-all mode, errors do not cancel the derived context and all functions are called.
+func NewGroup(ctx context.Context, concurrency sema.Concurrency) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+
+		sema:    sema.New(concurrency.Limit),
+		collect: concurrency.Force,
+	}, ctx
+}
+
+// Go calls f in a new goroutine, passing it the context of the group.
+// Go does not block; when the concurrency limit is reached, f is called once other functions have returned.
+//
+// If f panics, the panic is recovered using [recovery.Recover], and treated as an error returned from f.
+func (g *Group) Go(f func(ctx context.Context) error) {
+	g.wg.Go(func() {
+		g.sema.Lock()
+		defer g.sema.Unlock()
+
+		// when a previous function failed, don't bother starting.
+		if !g.collect && g.ctx.Err() != nil {
+			g.fail(context.Cause(g.ctx))
+			return
+		}
+
+		if err := g.call(f); err != nil {
+			g.fail(err)
+		}
+	})
+}
+
+// call calls f and recovers any panic.
+func (g *Group) call(f func(ctx context.Context) error) (err error) {
+	defer func() {
+		if e := recovery.Recover(recover()); e != nil {
+			err = e
+		}
+	}()
+
+	return f(g.ctx)
+}
+
+// fail records that a function failed with err.
+func (g *Group) fail(err error) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.errs = append(g.errs, err)
+	if !g.collect && len(g.errs) == 1 {
+		g.cancel(err)
+	}
+}
+
+// Wait blocks until all functions passed to [Group.Go] have returned.
+// It then cancels the context of the group.
+//
+// By default, it returns the first non-nil error returned by any function.
+// In collect-all mode, all errors are returned as if combined using [Combine].
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if g.collect {
+		return Combine(g.errs...)
+	}
+	if len(g.errs) == 0 {
+		return nil
+	}
+	return g.errs[0]
+}
@@ -0,0 +1,142 @@
+//spellchecker:words errorsx
+package errorsx_test
+
+//spellchecker:words context errors strings sync atomic testing time pkglib errorsx sema
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/sema"
+)
+
+var (
+	errGroupFirst  = errors.New("first")
+	errGroupSecond = errors.New("second")
+)
+
+func ExampleGroup() {
+	group, _ := errorsx.NewGroup(context.Background(), sema.Concurrency{Limit: 2})
+
+	var total atomic.Int64
+	for i := range 10 {
+		group.Go(func(ctx context.Context) error {
+			total.Add(int64(i))
+			return nil
+		})
+	}
+
+	fmt.Println(group.Wait())
+	fmt.Println(total.Load())
+
+	// Output: <nil>
+	// 45
+}
+
+func TestGroup_limit(t *testing.T) {
+	t.Parallel()
+
+	const limit = 3
+
+	group, _ := errorsx.NewGroup(t.Context(), sema.Concurrency{Limit: limit})
+
+	var current, maximum atomic.Int64
+	for range 20 {
+		group.Go(func(ctx context.Context) error {
+			now := current.Add(1)
+			defer current.Add(-1)
+
+			for {
+				old := maximum.Load()
+				if now <= old || maximum.CompareAndSwap(old, now) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want nil", err)
+	}
+	if got := maximum.Load(); got > limit {
+		t.Errorf("got %d concurrent calls, want at most %d", got, limit)
+	}
+}
+
+func TestGroup_firstError(t *testing.T) {
+	t.Parallel()
+
+	group, ctx := errorsx.NewGroup(t.Context(), sema.Concurrency{Limit: 1})
+
+	var calls atomic.Int64
+	group.Go(func(ctx context.Context) error {
+		calls.Add(1)
+		return errGroupFirst
+	})
+	for range 5 {
+		group.Go(func(ctx context.Context) error {
+			calls.Add(1)
+			return errGroupSecond
+		})
+	}
+
+	err := group.Wait()
+	if err == nil {
+		t.Fatal("Wait() = nil, want error")
+	}
+	if ctx.Err() == nil {
+		t.Error("context was not canceled")
+	}
+
+	// exactly one function should have been called.
+	// it may however not be the first one, as goroutines are not started in order.
+	if got := calls.Load(); got != 1 {
+		t.Errorf("got %d calls, want 1", got)
+	}
+	if context.Cause(ctx) != err { //nolint:errorlint // testing for identity
+		t.Errorf("context cause = %v, want %v", context.Cause(ctx), err)
+	}
+}
+
+func TestGroup_collectAll(t *testing.T) {
+	t.Parallel()
+
+	group, ctx := errorsx.NewGroup(t.Context(), sema.Concurrency{Force: true})
+
+	group.Go(func(ctx context.Context) error { return errGroupFirst })
+	group.Go(func(ctx context.Context) error { return nil })
+	group.Go(func(ctx context.Context) error { return errGroupSecond })
+
+	err := group.Wait()
+	if !errors.Is(err, errGroupFirst) || !errors.Is(err, errGroupSecond) {
+		t.Errorf("Wait() = %v, want both errors", err)
+	}
+	if context.Cause(ctx) != context.Canceled { //nolint:errorlint // testing for identity
+		t.Errorf("context cause = %v, want %v", context.Cause(ctx), context.Canceled)
+	}
+}
+
+func TestGroup_panic(t *testing.T) {
+	t.Parallel()
+
+	group, _ := errorsx.NewGroup(t.Context(), sema.Concurrency{})
+	group.Go(func(ctx context.Context) error {
+		panic("something went wrong")
+	})
+
+	err := group.Wait()
+	if err == nil {
+		t.Fatal("Wait() = nil, want error")
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "something went wrong") || !strings.Contains(msg, "goroutine") {
+		t.Errorf("Wait() = %q, want panic message with stack", msg)
+	}
+}
@@ -0,0 +1,154 @@
+//spellchecker:words errorsx
+package errorsx
+
+// Kind classifies an error independently of the layer it is reported in.
+// Kinds are mapped to http status codes by the httpx package and to exit codes by the exit package.
+//
+// The zero value is [KindUnknown].
+type Kind uint8
+
+// Kinds of errors supported by this package.
+const (
+	KindUnknown      Kind = iota // error of unknown kind
+	KindNotFound                 // a requested resource does not exist
+	KindInvalidInput             // input provided by the user is invalid
+	KindConflict                 // the operation conflicts with the current state
+	KindUnauthorized             // the caller is not authenticated, or its credentials are invalid
+	KindUnavailable              // a resource is temporarily unavailable
+	KindInternal                 // an internal error occurred
+)
+
+// String returns a human-readable representation of the kind.
+func (kind Kind) String() string {
+	switch kind {
+	case KindUnknown:
+		return "unknown"
+	case KindNotFound:
+		return "not found"
+	case KindInvalidInput:
+		return "invalid input"
+	case KindConflict:
+		return "conflict"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindUnavailable:
+		return "unavailable"
+	case KindInternal:
+		return "internal"
+	default:
+		return "invalid kind"
+	}
+}
+
+// kinded is implemented by errors that have a kind.
+type kinded interface {
+	error
+	ErrorKind() Kind
+}
+
+// retryable is implemented by errors that know if they are retryable.
+type retryable interface {
+	error
+	Retryable() bool
+}
+
+// userMessaged is implemented by errors with a message that is safe to show to users.
+type userMessaged interface {
+	error
+	UserMessage() string
+}
+
+// WithKind wraps err with the given kind.
+// The returned error behaves like err when calling Error, [errors.Is] or [errors.As].
+// If err is nil, returns nil.
+//
+// Error types can also declare their kind directly by implementing an "ErrorKind() Kind" method.
+func WithKind(err error, kind Kind) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{err: err, kind: kind}
+}
+
+// KindOf returns the kind of err.
+// The kind is determined by the first error in the chain of err with an "ErrorKind() Kind" method.
+// See [WithKind].
+//
+// If err is nil or does not have a kind, returns [KindUnknown].
+func KindOf(err error) Kind {
+	if ke, ok := AsType[kinded](err); ok {
+		return ke.ErrorKind()
+	}
+	return KindUnknown
+}
+
+// WithRetryable wraps err and marks it as retryable or not.
+// The returned error behaves like err when calling Error, [errors.Is] or [errors.As].
+// If err is nil, returns nil.
+//
+// Error types can also declare this property directly by implementing a "Retryable() bool" method.
+func WithRetryable(err error, retry bool) error {
+	if err == nil {
+		return nil
+	}
+	return &retryError{err: err, retry: retry}
+}
+
+// IsRetryable checks if the operation that caused err may succeed when retried.
+//
+// This is determined by the first error in the chain of err with a "Retryable() bool" method.
+// If there is no such error, errors of [KindUnavailable] are considered retryable.
+func IsRetryable(err error) bool {
+	if re, ok := AsType[retryable](err); ok {
+		return re.Retryable()
+	}
+	return KindOf(err) == KindUnavailable
+}
+
+// WithUserMessage wraps err with a message that is safe to show to users.
+// The returned error behaves like err when calling Error, [errors.Is] or [errors.As].
+// If err is nil, returns nil.
+//
+// Error types can also provide such a message by implementing a "UserMessage() string" method.
+func WithUserMessage(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	return &userMessageError{err: err, message: message}
+}
+
+// UserMessage returns the message of err that is safe to show to users.
+// The message is determined by the first error in the chain of err with a "UserMessage() string" method.
+func UserMessage(err error) (message string, ok bool) {
+	if ue, ok := AsType[userMessaged](err); ok {
+		return ue.UserMessage(), true
+	}
+	return "", false
+}
+
+type kindError struct {
+	err  error
+	kind Kind
+}
+
+func (ke *kindError) Error() string   { return ke.err.Error() }
+func (ke *kindError) Unwrap() error   { return ke.err }
+func (ke *kindError) ErrorKind() Kind { return ke.kind }
+
+type retryError struct {
+	err   error
+	retry bool
+}
+
+func (re *retryError) Error() string   { return re.err.Error() }
+func (re *retryError) Unwrap() error   { return re.err }
+func (re *retryError) Retryable() bool { return re.retry }
+
+type userMessageError struct {
+	err     error
+	message string
+}
+
+func (ue *userMessageError) Error() string       { return ue.err.Error() }
+func (ue *userMessageError) Unwrap() error       { return ue.err }
+func (ue *userMessageError) UserMessage() string { return ue.message }
@@ -0,0 +1,100 @@
+//spellchecker:words errorsx
+package errorsx_test
+
+//spellchecker:words errors testing pkglib errorsx
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+var errKindBase = errors.New("base")
+
+// customKind is an error that declares its own kind.
+type customKind struct{}
+
+func (customKind) Error() string           { return "custom" }
+func (customKind) ErrorKind() errorsx.Kind { return errorsx.KindConflict }
+
+func ExampleWithKind() {
+	errUserNotFound := errorsx.WithKind(errors.New("user not found"), errorsx.KindNotFound) //nolint:err113 // example
+
+	err := fmt.Errorf("failed to load profile: %w", errUserNotFound)
+	fmt.Println(err)
+	fmt.Println(errorsx.KindOf(err))
+	fmt.Println(errors.Is(err, errUserNotFound))
+
+	// Output: failed to load profile: user not found
+	// not found
+	// true
+}
+
+func TestKindOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want errorsx.Kind
+	}{
+		{"nil error", nil, errorsx.KindUnknown},
+		{"plain error", errKindBase, errorsx.KindUnknown},
+		{"kinded error", errorsx.WithKind(errKindBase, errorsx.KindInvalidInput), errorsx.KindInvalidInput},
+		{"wrapped kinded error", fmt.Errorf("wrap: %w", errorsx.WithKind(errKindBase, errorsx.KindInternal)), errorsx.KindInternal},
+		{"outermost kind wins", errorsx.WithKind(errorsx.WithKind(errKindBase, errorsx.KindInternal), errorsx.KindNotFound), errorsx.KindNotFound},
+		{"custom kind", fmt.Errorf("wrap: %w", customKind{}), errorsx.KindConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := errorsx.KindOf(tt.err); got != tt.want {
+				t.Errorf("KindOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"plain error", errKindBase, false},
+		{"unavailable error", errorsx.WithKind(errKindBase, errorsx.KindUnavailable), true},
+		{"marked retryable", errorsx.WithRetryable(errKindBase, true), true},
+		{"marked non-retryable unavailable", errorsx.WithRetryable(errorsx.WithKind(errKindBase, errorsx.KindUnavailable), false), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := errorsx.IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserMessage(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := errorsx.UserMessage(errKindBase); ok {
+		t.Error("UserMessage() of plain error returned ok")
+	}
+
+	err := fmt.Errorf("internal details: %w", errorsx.WithUserMessage(errKindBase, "please try again"))
+	got, ok := errorsx.UserMessage(err)
+	if !ok || got != "please try again" {
+		t.Errorf("UserMessage() = %q, %v, want %q, true", got, ok, "please try again")
+	}
+	if !errors.Is(err, errKindBase) {
+		t.Error("WithUserMessage() does not wrap the original error")
+	}
+}
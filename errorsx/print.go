@@ -0,0 +1,114 @@
+//spellchecker:words errorsx
+package errorsx
+
+//spellchecker:words strconv strings
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PrintOptions determine how a [Tree] is printed.
+type PrintOptions struct {
+	// Indent is written once for each level of nesting.
+	// Defaults to two spaces.
+	Indent string
+
+	// Types indicates if the type of each error should be printed.
+	Types bool
+
+	// Stacks indicates if stacks captured by [WithStack] should be printed.
+	Stacks bool
+
+	// Compact indicates that wrapped errors whose message is already contained
+	// in the message of the error wrapping them should be omitted.
+	// Errors wrapped by omitted errors are still printed.
+	//
+	// This is typically the case for errors created by [fmt.Errorf] or [errors.Join].
+	Compact bool
+}
+
+// Fprint prints the tree of err to w.
+// See [NewTree] and [Tree.Print].
+func Fprint(w io.Writer, err error, opts PrintOptions) error {
+	return NewTree(err).Print(w, opts)
+}
+
+// Print prints the tree in a human-readable way to w.
+//
+// Each error is printed on its own line(s), with wrapped errors indented below the error wrapping them.
+// See [PrintOptions] for how the output can be customized.
+func (tree Tree) Print(w io.Writer, opts PrintOptions) error {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+
+	var builder strings.Builder
+	opts.tree(&builder, tree, 0, nil)
+
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return fmt.Errorf("failed to write error tree: %w", err)
+	}
+	return nil
+}
+
+// String formats the tree including types and stacks.
+func (tree Tree) String() string {
+	var builder strings.Builder
+	_ = tree.Print(&builder, PrintOptions{Types: true, Stacks: true}) // writing to a builder never fails
+	return builder.String()
+}
+
+// tree writes tree at the given depth into builder.
+// parent is the message of the closest printed ancestor, if any.
+func (opts PrintOptions) tree(builder *strings.Builder, tree Tree, depth int, parent *string) {
+	omit := opts.Compact && parent != nil && strings.Contains(*parent, tree.Message)
+
+	childDepth := depth
+	if !omit {
+		opts.message(builder, tree, depth)
+		parent = &tree.Message
+		childDepth = depth + 1
+	}
+
+	if opts.Stacks && len(tree.Stack) > 0 {
+		opts.stack(builder, tree.Stack, depth)
+	}
+
+	for _, child := range tree.Unwrap {
+		opts.tree(builder, child, childDepth, parent)
+	}
+}
+
+// message writes the message (and type) of tree at the given depth.
+func (opts PrintOptions) message(builder *strings.Builder, tree Tree, depth int) {
+	prefix := strings.Repeat(opts.Indent, depth)
+	for i, line := range strings.Split(tree.Message, "\n") {
+		builder.WriteString(prefix)
+		builder.WriteString(line)
+		if i == 0 && opts.Types {
+			builder.WriteString(" (")
+			builder.WriteString(tree.Type)
+			builder.WriteString(")")
+		}
+		builder.WriteString("\n")
+	}
+}
+
+// stack writes the given frames at the given depth.
+func (opts PrintOptions) stack(builder *strings.Builder, frames []Frame, depth int) {
+	prefix := strings.Repeat(opts.Indent, depth+1)
+	for _, frame := range frames {
+		builder.WriteString(prefix)
+		builder.WriteString(frame.Function)
+		builder.WriteString("\n")
+
+		builder.WriteString(prefix)
+		builder.WriteString("\t")
+		builder.WriteString(frame.File)
+		builder.WriteString(":")
+		builder.WriteString(strconv.Itoa(frame.Line))
+		builder.WriteString("\n")
+	}
+}
@@ -0,0 +1,106 @@
+//spellchecker:words errorsx
+package errorsx
+
+//spellchecker:words runtime strconv strings
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// maxStackDepth is the maximum number of frames captured by [WithStack].
+const maxStackDepth = 64
+
+// Stack represents a captured call stack.
+// It holds the program counters as returned by [runtime.Callers].
+type Stack []uintptr
+
+// Frame represents a single frame of a [Stack].
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Frames resolves the program counters in the stack into frames.
+func (stack Stack) Frames() []Frame {
+	if len(stack) == 0 {
+		return nil
+	}
+
+	frames := make([]Frame, 0, len(stack))
+
+	iter := runtime.CallersFrames(stack)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, Frame{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+		})
+		if !more {
+			break
+		}
+	}
+
+	return frames
+}
+
+// String formats the stack similar to [runtime/debug.Stack].
+// Each frame consists of the function name on one line,
+// and the file and line number on a second line indented by a tab.
+func (stack Stack) String() string {
+	var builder strings.Builder
+	for _, frame := range stack.Frames() {
+		builder.WriteString(frame.Function)
+		builder.WriteString("\n\t")
+		builder.WriteString(frame.File)
+		builder.WriteString(":")
+		builder.WriteString(strconv.Itoa(frame.Line))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// WithStack wraps err with the stack of the caller.
+// The returned error behaves exactly like err when calling Error, [errors.Is] or [errors.As].
+// The stack can be retrieved using [StackOf] and is rendered by [Tree].
+//
+// If err is nil, returns nil.
+// If err already holds a stack, it is returned unchanged.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := AsType[*stackError](err); ok {
+		return err
+	}
+
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs) // skip runtime.Callers and WithStack
+	return &stackError{err: err, stack: Stack(pcs[:n])}
+}
+
+// StackOf returns the outermost stack held by err, if any.
+// See [WithStack].
+func StackOf(err error) (Stack, bool) {
+	se, ok := AsType[*stackError](err)
+	if !ok {
+		return nil, false
+	}
+	return se.stack, true
+}
+
+// stackError is an error that holds a stack.
+type stackError struct {
+	err   error
+	stack Stack
+}
+
+func (se *stackError) Error() string {
+	return se.err.Error()
+}
+
+func (se *stackError) Unwrap() error {
+	return se.err
+}
@@ -0,0 +1,114 @@
+//spellchecker:words errorsx
+package errorsx
+
+//spellchecker:words encoding json iter
+import (
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"iter"
+)
+
+//spellchecker:words nolint errorlint
+
+// Unwrap returns the errors directly wrapped by err.
+// It supports both errors implementing an "Unwrap() error" and an "Unwrap() []error" method.
+// Nil errors are omitted from the result.
+func Unwrap(err error) []error {
+	var children []error
+	switch x := err.(type) { //nolint:errorlint // want to unwrap directly
+	case interface{ Unwrap() error }:
+		children = []error{x.Unwrap()}
+	case interface{ Unwrap() []error }:
+		children = x.Unwrap()
+	}
+
+	result := make([]error, 0, len(children))
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		result = append(result, child)
+	}
+	return result
+}
+
+// Walk iterates over err and all errors wrapped by it.
+// The iteration is depth-first and visits each error before the errors wrapped by it.
+// Each error is yielded together with its depth, err itself has depth 0.
+//
+// When err is nil, no errors are yielded.
+func Walk(err error) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		if err == nil {
+			return
+		}
+		walk(yield, err, 0)
+	}
+}
+
+// walk implements [Walk].
+// The return value indicates if the caller should continue.
+func walk(yield func(int, error) bool, err error, depth int) bool {
+	if !yield(depth, err) {
+		return false
+	}
+	for _, child := range Unwrap(err) {
+		if !walk(yield, child, depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// Tree represents an error along with all the errors it wraps.
+//
+// A Tree can be printed as text using [Tree.Print] and encoded as json using [encoding/json/v2].
+type Tree struct {
+	Err error `json:"-"` // Err is the error represented by this tree
+
+	Message string `json:"message"` // Message is the result of calling the Error() method
+	Type    string `json:"type"`    // Type is the type of error
+	Source  string `json:"-"`       // Source is the result of formatting the error as a go source
+
+	Stack  []Frame `json:"stack,omitempty"`  // Stack is the stack captured by [WithStack], if any
+	Unwrap []Tree  `json:"unwrap,omitempty"` // Unwrap holds the trees of wrapped errors
+}
+
+// NewTree builds the tree for the given error.
+//
+// Errors created by [WithStack] do not create their own node in the tree.
+// Instead, the captured stack is stored in the node of the error they wrap.
+func NewTree(err error) Tree {
+	if se, ok := err.(*stackError); ok { //nolint:errorlint // want to check the exact type
+		tree := NewTree(se.err)
+		if tree.Stack == nil {
+			tree.Stack = se.stack.Frames()
+		}
+		return tree
+	}
+
+	tree := Tree{
+		Err:     err,
+		Message: fmt.Sprintf("%s", err),
+		Type:    fmt.Sprintf("%T", err),
+		Source:  fmt.Sprintf("%#v", err),
+	}
+
+	children := Unwrap(err)
+	tree.Unwrap = make([]Tree, len(children))
+	for i, child := range children {
+		tree.Unwrap[i] = NewTree(child)
+	}
+
+	return tree
+}
+
+// EncodeTree writes the tree of err as json to w.
+// See [NewTree].
+func EncodeTree(w io.Writer, err error) error {
+	if err := json.MarshalWrite(w, NewTree(err)); err != nil {
+		return fmt.Errorf("failed to encode error tree: %w", err)
+	}
+	return nil
+}
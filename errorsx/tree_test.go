@@ -0,0 +1,119 @@
+//spellchecker:words errorsx
+package errorsx_test
+
+//spellchecker:words errors strings testing pkglib errorsx
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+var (
+	errTreeLeaf  = errors.New("leaf")
+	errTreeOther = errors.New("other")
+)
+
+func ExampleWalk() {
+	err := fmt.Errorf("wrapped: %w", errors.Join(errTreeLeaf, errTreeOther))
+
+	for depth, err := range errorsx.Walk(err) {
+		fmt.Printf("%s%q\n", strings.Repeat("  ", depth), err.Error())
+	}
+
+	// Output: "wrapped: leaf\nother"
+	//   "leaf\nother"
+	//     "leaf"
+	//     "other"
+}
+
+func ExampleFprint() {
+	err := fmt.Errorf("wrapped: %w", errors.Join(errTreeLeaf, errTreeOther))
+
+	_ = errorsx.Fprint(os.Stdout, err, errorsx.PrintOptions{Types: true})
+
+	// Output: wrapped: leaf (*fmt.wrapError)
+	// other
+	//   leaf (*errors.joinError)
+	//   other
+	//     leaf (*errors.errorString)
+	//     other (*errors.errorString)
+}
+
+func ExampleFprint_compact() {
+	err := fmt.Errorf("wrapped: %w", errors.Join(errTreeLeaf, errTreeOther))
+
+	// in compact mode, errors that do not add any information are omitted.
+	_ = errorsx.Fprint(os.Stdout, err, errorsx.PrintOptions{Compact: true})
+
+	// Output: wrapped: leaf
+	// other
+}
+
+func ExampleEncodeTree() {
+	err := fmt.Errorf("wrapped: %w", errTreeLeaf)
+
+	_ = errorsx.EncodeTree(os.Stdout, err)
+
+	// Output: {"message":"wrapped: leaf","type":"*fmt.wrapError","unwrap":[{"message":"leaf","type":"*errors.errorString"}]}
+}
+
+func TestWithStack(t *testing.T) {
+	t.Parallel()
+
+	if err := errorsx.WithStack(nil); err != nil {
+		t.Errorf("WithStack(nil) = %v, want nil", err)
+	}
+
+	err := errorsx.WithStack(errTreeLeaf)
+	if !errors.Is(err, errTreeLeaf) {
+		t.Error("WithStack() does not wrap the original error")
+	}
+	if err.Error() != errTreeLeaf.Error() {
+		t.Errorf("WithStack().Error() = %q, want %q", err.Error(), errTreeLeaf.Error())
+	}
+
+	stack, ok := errorsx.StackOf(fmt.Errorf("wrapped: %w", err))
+	if !ok {
+		t.Fatal("StackOf() did not find stack")
+	}
+	frames := stack.Frames()
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "TestWithStack") {
+		t.Errorf("StackOf() returned unexpected stack %v", frames)
+	}
+
+	if again := errorsx.WithStack(err); again != err { //nolint:errorlint // testing for identity
+		t.Error("WithStack() did wrap an error that already has a stack")
+	}
+}
+
+func TestNewTree_stack(t *testing.T) {
+	t.Parallel()
+
+	err := fmt.Errorf("wrapped: %w", errorsx.WithStack(errTreeLeaf))
+	tree := errorsx.NewTree(err)
+
+	// the stack error should not show up in the tree itself
+	if len(tree.Unwrap) != 1 {
+		t.Fatalf("NewTree() has %d children, want 1", len(tree.Unwrap))
+	}
+	leaf := tree.Unwrap[0]
+	if leaf.Err != errTreeLeaf { //nolint:errorlint // testing for identity
+		t.Errorf("NewTree() child is %#v, want %#v", leaf.Err, errTreeLeaf)
+	}
+	if len(leaf.Stack) == 0 {
+		t.Error("NewTree() child has no stack")
+	}
+
+	// the stack should be printed
+	var builder strings.Builder
+	if err := tree.Print(&builder, errorsx.PrintOptions{Stacks: true, Compact: true}); err != nil {
+		t.Fatalf("Print() returned error %v", err)
+	}
+	if got := builder.String(); !strings.HasPrefix(got, "wrapped: leaf\n") || !strings.Contains(got, "TestNewTree_stack") {
+		t.Errorf("Print() = %q, want message followed by stack", got)
+	}
+}
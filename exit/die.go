@@ -1,30 +1,95 @@
 //spellchecker:words exit
 package exit
 
+//spellchecker:words encoding json pkglib errorsx
 import (
+	"encoding/json/v2"
 	"fmt"
 	"io"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+// Format determines how [DieFormat] prints errors.
+type Format uint8
+
+const (
+	// FormatText prints errors as human-readable text.
+	// See [Die] for details.
+	FormatText Format = iota
+
+	// FormatJSON prints errors as a single line json document.
+	// The document is an object holding the exit code in the "code" key,
+	// along with the "message", "type" and "unwrap" keys of the [errorsx.Tree] of the error.
+	// If the error has a message safe to show to users, see [errorsx.UserMessage], it is held in the "user_message" key.
+	FormatJSON
 )
 
 // Die prints a non-nil err to w and returns an error with an exit code.
 // An error without an error code is wrapped with wrap, which should hold an exit code.
 // If err is nil, it does nothing and returns nil.
+//
+// The error is printed using [errorsx.Fprint] in compact mode.
+// This means that wrapped errors are only printed when they add information,
+// and stacks captured using [errorsx.WithStack] are included.
+// If the error has a message safe to show to users, see [errorsx.UserMessage], it is printed on its own line first.
 func Die(w io.Writer, err error, wrap error) error {
+	return DieFormat(w, err, wrap, FormatText)
+}
+
+// DieFormat is like [Die], but prints the error in the given format.
+func DieFormat(w io.Writer, err error, wrap error, format Format) error {
 	// fast case: not an error
 	if err == nil {
 		return nil
 	}
 
 	// if we do not have a code, wrap the error.
-	// The generic exit code passed here is discarded.
-	if _, ok := CodeFromError(err, 1); !ok {
+	// The generic exit code passed here is discarded.
+	if _, ok := CodeFromError(err, ExitFailure); !ok {
 		err = fmt.Errorf("%w: %w", wrap, err)
 	}
 
-	// print the error message to standard error in a wrapped way
-	if message := fmt.Sprint(err); message != "" {
-		_, _ = fmt.Fprintln(w, message) // no way to report the failure
+	// print the error tree to standard error in a wrapped way
+	switch format {
+	case FormatJSON:
+		_ = printJSON(w, err) // no way to report the failure
+	case FormatText:
+		fallthrough
+	default:
+		if message, ok := errorsx.UserMessage(err); ok && message != "" {
+			_, _ = fmt.Fprintln(w, message) // no way to report the failure
+		}
+		if message := fmt.Sprint(err); message != "" {
+			_ = errorsx.Fprint(w, err, dieOptions) // no way to report the failure
+		}
 	}
 
 	return err
 }
+
+// dieOptions are the options used to print errors in [Die].
+var dieOptions = errorsx.PrintOptions{Compact: true, Stacks: true}
+
+// errorDocument is the document printed by [FormatJSON].
+type errorDocument struct {
+	Code        ExitCode `json:"code"`
+	UserMessage string   `json:"user_message,omitempty"`
+	errorsx.Tree
+}
+
+// printJSON prints err as an [errorDocument] followed by a newline.
+func printJSON(w io.Writer, err error) error {
+	code, _ := CodeFromError(err, ExitFailure)
+
+	message, _ := errorsx.UserMessage(err)
+
+	doc, e := json.Marshal(errorDocument{Code: code, UserMessage: message, Tree: errorsx.NewTree(err)})
+	if e != nil {
+		return fmt.Errorf("failed to marshal error: %w", e)
+	}
+	if _, e := fmt.Fprintf(w, "%s\n", doc); e != nil {
+		return fmt.Errorf("failed to write error: %w", e)
+	}
+	return nil
+}
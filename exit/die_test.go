@@ -0,0 +1,47 @@
+//spellchecker:words exit
+package exit_test
+
+//spellchecker:words errors pkglib errorsx exit
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/exit"
+)
+
+var (
+	errGeneric = exit.NewErrorWithCode("generic error", 3)
+	errFirst   = errors.New("first problem")
+	errSecond  = errors.New("second problem")
+)
+
+func ExampleDie() {
+	err := exit.Die(os.Stdout, fmt.Errorf("something went wrong: %w", errors.Join(errFirst, errSecond)), errGeneric)
+
+	code, _ := exit.CodeFromError(err, 1)
+	fmt.Printf("exit code: %d\n", code)
+
+	// Output: generic error: something went wrong: first problem
+	// second problem
+	// exit code: 3
+}
+
+func ExampleDieFormat() {
+	err := exit.DieFormat(os.Stdout, exit.ExitConfig.Wrap(errFirst), errGeneric, exit.FormatJSON)
+
+	code, _ := exit.CodeFromError(err, 1)
+	fmt.Printf("exit code: %d\n", code)
+
+	// Output: {"code":78,"message":"first problem","type":"*exit.wrapError","unwrap":[{"message":"first problem","type":"*errors.errorString"}]}
+	// exit code: 78
+}
+
+func ExampleDie_userMessage() {
+	err := errorsx.WithUserMessage(fmt.Errorf("failed to open config: %w", errFirst), "the configuration file could not be read")
+	_ = exit.Die(os.Stdout, err, errGeneric)
+
+	// Output: the configuration file could not be read
+	// generic error: failed to open config: first problem
+}
@@ -17,11 +17,13 @@ type errorWithCode interface {
 var (
 	_ errorWithCode = &codeError{}
 	_ errorWithCode = &exitError{}
+	_ errorWithCode = &wrapError{}
 )
 
 // CodeFromError returns the ExitCode contained in error, if any.
-// See [NewErrorWithCode] and [ErrorFromExit].
+// See [NewErrorWithCode], [ExitCode.Wrap] and [FromExitError].
 // The exit code is found by [errors.As] unwrapping into an error created by this package.
+// If there is no such error, but err has an [errorsx.Kind], the exit code is determined using [KindCode].
 //
 // When err is nil, returns code 0.
 // When err does not hold any exit code, returns the provided generic code and false.
@@ -32,9 +34,29 @@ func CodeFromError(err error, generic ExitCode) (code ExitCode, ok bool) {
 	if codeErr, ok := errorsx.AsType[errorWithCode](err); ok {
 		return codeErr.exitCode(), true
 	}
+	if code, ok := KindCode(errorsx.KindOf(err)); ok {
+		return code, true
+	}
 	return generic, false
 }
 
+// kindCodes maps error kinds to exit codes.
+var kindCodes = map[errorsx.Kind]ExitCode{
+	errorsx.KindNotFound:     ExitNoInput,
+	errorsx.KindInvalidInput: ExitDataErr,
+	errorsx.KindConflict:     ExitCantCreate,
+	errorsx.KindUnauthorized: ExitNoPerm,
+	errorsx.KindUnavailable:  ExitUnavailable,
+	errorsx.KindInternal:     ExitSoftware,
+}
+
+// KindCode returns the exit code corresponding to the given kind of error.
+// If there is no such exit code, returns 0 and false.
+func KindCode(kind errorsx.Kind) (ExitCode, bool) {
+	code, ok := kindCodes[kind]
+	return code, ok
+}
+
 // NewErrorWithCode creates a new error that additionally holds the given exit code.
 func NewErrorWithCode(message string, code ExitCode) error {
 	return &codeError{message: message, code: code}
@@ -1,12 +1,13 @@
 //spellchecker:words exit
 package exit_test
 
-//spellchecker:words errors testing pkglib exit testlib
+//spellchecker:words errors testing pkglib errorsx exit testlib
 import (
 	"errors"
 	"fmt"
 	"testing"
 
+	"go.tkw01536.de/pkglib/errorsx"
 	"go.tkw01536.de/pkglib/exit"
 	"go.tkw01536.de/pkglib/testlib"
 )
@@ -15,6 +16,8 @@ var (
 	errStuff        = exit.NewErrorWithCode("stuff", 1)
 	errStuffWrapped = fmt.Errorf("wrapping: %w", errStuff)
 	errUnrelated    = errors.New("unrelated")
+	errKind         = errorsx.WithKind(errUnrelated, errorsx.KindNotFound)
+	errKindAndCode  = errorsx.WithKind(errStuff, errorsx.KindNotFound)
 )
 
 func TestCodeFromError(t *testing.T) {
@@ -60,6 +63,20 @@ func TestCodeFromError(t *testing.T) {
 			wantCode: 10,
 			wantOK:   false,
 		},
+		{
+			name:     "error with kind returns kind code",
+			err:      errKind,
+			generic:  10,
+			wantCode: 66,
+			wantOK:   true,
+		},
+		{
+			name:     "error code takes precedence over kind",
+			err:      errKindAndCode,
+			generic:  10,
+			wantCode: 1,
+			wantOK:   true,
+		},
 		{
 			name:     "unwrapped exec error doesn't return exit code",
 			err:      errExitCode,
@@ -89,3 +106,22 @@ func TestCodeFromError(t *testing.T) {
 		})
 	}
 }
+
+func TestExitCode_Wrap(t *testing.T) {
+	t.Parallel()
+
+	if err := exit.ExitUsage.Wrap(nil); err != nil {
+		t.Errorf("Wrap(nil) = %v, want nil", err)
+	}
+
+	err := fmt.Errorf("context: %w", exit.ExitUsage.Wrap(errUnrelated))
+	if code, ok := exit.CodeFromError(err, exit.ExitFailure); code != exit.ExitUsage || !ok {
+		t.Errorf("CodeFromError() = %d, %v, want %d, true", code, ok, exit.ExitUsage)
+	}
+	if !errors.Is(err, errUnrelated) {
+		t.Error("Wrap() does not wrap the original error")
+	}
+	if got, want := err.Error(), "context: unrelated"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+//spellchecker:words exit
+package exit
+
+//spellchecker:words context errors signal sync syscall pkglib errorsx recovery stream
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/recovery"
+	"go.tkw01536.de/pkglib/stream"
+)
+
+// Environment variables used by [Runner].
+const (
+	// DebugEnv enables full panic reports when non-empty.
+	DebugEnv = "PKGLIB_DEBUG"
+
+	// FormatEnv selects [FormatJSON] when set to "json".
+	FormatEnv = "PKGLIB_ERROR_FORMAT"
+)
+
+// errGeneric is the default error used to wrap errors without exit code in [Runner].
+var errGeneric = NewErrorWithCode("error", ExitFailure)
+
+// Main runs the main function of a command line program and exits.
+// It is equivalent to an empty [Runner]:
+//
+//	func main() {
+//		exit.Main(func(ctx context.Context, str stream.IOStream, args []string) error {
+//			// ... actual program here ...
+//		})
+//	}
+func Main(main func(ctx context.Context, str stream.IOStream, args []string) error) {
+	var runner Runner
+	runner.Main(main)
+}
+
+// Runner runs the main function of a command line program.
+//
+// All fields are optional and default to values appropriate for a real program.
+// Tests may override them to inject a stream and capture the exit code.
+type Runner struct {
+	// Stream is passed to the main function and used to report errors.
+	// Defaults to [stream.FromEnv].
+	// Any nil streams of a non-zero Stream are replaced by [stream.Null].
+	Stream stream.IOStream
+
+	// Args are passed to the main function.
+	// Defaults to os.Args[1:].
+	Args []string
+
+	// Signals cancel the context passed to the main function.
+	// When any of them is received, the exit code is 128 plus the signal number.
+	// A second signal is not intercepted, and typically terminates the program immediately.
+	//
+	// Defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+
+	// Generic wraps errors that do not hold an exit code, see [Die].
+	// Defaults to an error with exit code 1.
+	Generic error
+
+	// Format is the format errors are printed in, see [DieFormat].
+	// When it is [FormatText], the [FormatEnv] environment variable may select a different format.
+	Format Format
+
+	// Exit is called with the final exit code.
+	// Defaults to [ExitCode.Return].
+	Exit func(code ExitCode)
+
+	// Getenv is used to check the [DebugEnv] and [FormatEnv] variables.
+	// Defaults to [os.Getenv].
+	Getenv func(key string) string
+}
+
+// Main calls main and exits with the appropriate exit code.
+// See [Runner.Run] for details.
+func (r Runner) Main(main func(ctx context.Context, str stream.IOStream, args []string) error) {
+	code := r.Run(main)
+	if r.Exit == nil {
+		code.Return()
+		return
+	}
+	r.Exit(code)
+}
+
+// Run calls main and returns the appropriate exit code.
+//
+// When main returns an error, it is printed to standard error using [DieFormat].
+// The exit code is then determined using [CodeFromError].
+//
+// When main panics, a short panic message is printed, and the exit code is that of [errorsx.KindInternal].
+// If the [DebugEnv] environment variable is non-empty, the panic report includes a stack trace.
+//
+// When one of the signals is received, the context passed to main is canceled.
+// If main has not yet returned, the exit code is then 128 plus the signal number, e.g. 130 for SIGINT and 143 for SIGTERM.
+// Errors returned by main because the context was canceled are not printed.
+func (r Runner) Run(main func(ctx context.Context, str stream.IOStream, args []string) error) ExitCode {
+	str := stream.FromEnv()
+	if r.Stream != (stream.IOStream{}) {
+		str = stream.NewIOStream(r.Stream.Stdout, r.Stream.Stderr, r.Stream.Stdin)
+	}
+	args := r.Args
+	if args == nil && len(os.Args) > 0 {
+		args = os.Args[1:]
+	}
+	generic := r.Generic
+	if generic == nil {
+		generic = errGeneric
+	}
+	format := r.Format
+	if format == FormatText && r.getenv(FormatEnv) == "json" {
+		format = FormatJSON
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	received := r.notify(cancel)
+
+	err := r.call(ctx, str, args, main)
+
+	// if we received a signal, report it as the exit code
+	if sig := received(); sig != nil {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			_ = DieFormat(str.Stderr, err, generic, format)
+		}
+		return signalCode(sig)
+	}
+
+	err = DieFormat(str.Stderr, err, generic, format)
+	code, _ := CodeFromError(err, ExitFailure)
+	return code
+}
+
+// call calls main and turns panics into errors.
+func (r Runner) call(ctx context.Context, str stream.IOStream, args []string, main func(ctx context.Context, str stream.IOStream, args []string) error) (err error) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+
+		// only include the stack when debugging
+		message := fmt.Sprintf("panic: %v (set %s=1 for a stack trace)", value, DebugEnv)
+		if r.getenv(DebugEnv) != "" {
+			message = "panic: " + recovery.Recover(value).Error()
+		}
+
+		err = errorsx.WithKind(&panicError{message: message}, errorsx.KindInternal)
+	}()
+
+	return main(ctx, str, args)
+}
+
+// notify starts listening for signals and cancels the context once one is received.
+// The returned function is called once main has completed.
+// It stops listening, and returns the signal that was received before main completed, if any.
+func (r Runner) notify(cancel context.CancelCauseFunc) func() os.Signal {
+	signals := r.Signals
+	if signals == nil {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, signals...)
+
+	var (
+		m        sync.Mutex
+		finished bool      // has main completed?
+		received os.Signal // signal received before main completed
+	)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		select {
+		case sig := <-c:
+			// stop intercepting, so that a second signal uses default behavior
+			signal.Stop(c)
+
+			// signals after main has completed do not change the exit code
+			m.Lock()
+			defer m.Unlock()
+			if finished {
+				return
+			}
+			received = sig
+			cancel(&signalError{signal: sig})
+		case <-done:
+		}
+	})
+
+	return func() os.Signal {
+		m.Lock()
+		finished = true
+		m.Unlock()
+
+		signal.Stop(c)
+		close(done)
+		wg.Wait()
+		return received
+	}
+}
+
+// signalCode returns the conventional exit code for a program terminated by sig.
+func signalCode(sig os.Signal) ExitCode {
+	if s, ok := sig.(syscall.Signal); ok {
+		return Code(128 + int(s))
+	}
+	return ExitFailure
+}
+
+func (r Runner) getenv(key string) string {
+	if r.Getenv == nil {
+		return os.Getenv(key)
+	}
+	return r.Getenv(key)
+}
+
+// signalError is the cause of the context passed to main when a signal is received.
+type signalError struct {
+	signal os.Signal
+}
+
+func (se *signalError) Error() string {
+	return "received signal: " + se.signal.String()
+}
+
+func (se *signalError) Unwrap() error {
+	return context.Canceled
+}
+
+// panicError represents a panic in the main function.
+type panicError struct {
+	message string
+}
+
+func (pe *panicError) Error() string {
+	return pe.message
+}
@@ -0,0 +1,111 @@
+//spellchecker:words exit
+package exit_test
+
+//spellchecker:words bytes context strings testing pkglib exit stream
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/exit"
+	"go.tkw01536.de/pkglib/stream"
+)
+
+func ExampleRunner() {
+	var code exit.ExitCode
+	runner := exit.Runner{
+		Stream: stream.NonInteractive(&strings.Builder{}),
+		Args:   []string{"hello", "world"},
+		Exit:   func(c exit.ExitCode) { code = c },
+	}
+
+	runner.Main(func(ctx context.Context, str stream.IOStream, args []string) error {
+		fmt.Println(args)
+		return exit.NewErrorWithCode("something went wrong", 42)
+	})
+	fmt.Println(code)
+
+	// Output: [hello world]
+	// 42
+}
+
+func TestRunner_Run(t *testing.T) {
+	t.Parallel()
+
+	errWithCode := exit.NewErrorWithCode("with code", 4)
+
+	tests := []struct {
+		name       string
+		main       func(ctx context.Context, str stream.IOStream, args []string) error
+		debug      bool
+		wantCode   exit.ExitCode
+		wantStderr string
+		wantStack  bool
+	}{
+		{
+			name:     "no error",
+			main:     func(context.Context, stream.IOStream, []string) error { return nil },
+			wantCode: 0,
+		},
+		{
+			name:       "error with code",
+			main:       func(context.Context, stream.IOStream, []string) error { return errWithCode },
+			wantCode:   4,
+			wantStderr: "with code\n",
+		},
+		{
+			name:       "error without code",
+			main:       func(context.Context, stream.IOStream, []string) error { return errUnrelated },
+			wantCode:   1,
+			wantStderr: "error: unrelated\n",
+		},
+		{
+			name:       "panic",
+			main:       func(context.Context, stream.IOStream, []string) error { panic("boom") },
+			wantCode:   70,
+			wantStderr: "panic: boom (set PKGLIB_DEBUG=1 for a stack trace)\n",
+		},
+		{
+			name:       "panic with debug",
+			main:       func(context.Context, stream.IOStream, []string) error { panic("boom") },
+			debug:      true,
+			wantCode:   70,
+			wantStderr: "panic: boom\n",
+			wantStack:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stderr bytes.Buffer
+			runner := exit.Runner{
+				Stream: stream.NewIOStream(nil, &stderr, nil),
+				Args:   []string{},
+				Getenv: func(key string) string {
+					if tt.debug && key == exit.DebugEnv {
+						return "1"
+					}
+					return ""
+				},
+			}
+
+			if got := runner.Run(tt.main); got != tt.wantCode {
+				t.Errorf("Run() = %d, want %d", got, tt.wantCode)
+			}
+
+			got := stderr.String()
+			if tt.wantStack {
+				if !strings.HasPrefix(got, tt.wantStderr) || !strings.Contains(got, "goroutine") {
+					t.Errorf("Run() printed %q, want prefix %q and stack", got, tt.wantStderr)
+				}
+				return
+			}
+			if got != tt.wantStderr {
+				t.Errorf("Run() printed %q, want %q", got, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+//spellchecker:words exit
+package exit_test
+
+//spellchecker:words bytes context syscall testing pkglib exit stream
+import (
+	"bytes"
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"go.tkw01536.de/pkglib/exit"
+	"go.tkw01536.de/pkglib/stream"
+)
+
+func TestRunner_Run_signal(t *testing.T) {
+	t.Parallel()
+
+	var stderr bytes.Buffer
+	runner := exit.Runner{
+		Stream:  stream.NewIOStream(nil, &stderr, nil),
+		Args:    []string{},
+		Signals: []os.Signal{syscall.SIGUSR1},
+	}
+
+	code := runner.Run(func(ctx context.Context, str stream.IOStream, args []string) error {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+			return err
+		}
+
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if want := exit.ExitCode(128 + syscall.SIGUSR1); code != want {
+		t.Errorf("Run() = %d, want %d", code, want)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Run() printed %q, want nothing", stderr.String())
+	}
+}
@@ -0,0 +1,58 @@
+//spellchecker:words exit
+package exit
+
+//spellchecker:words sysexits NOINPUT NOUSER NOHOST OSERR OSFILE CANTCREAT IOERR TEMPFAIL NOPERM
+
+// Exit codes with predefined meanings.
+//
+// Except for [ExitOK] and [ExitFailure], these follow the BSD sysexits.h header.
+// Any of these can be attached to an error using [ExitCode.Wrap].
+const (
+	ExitOK      ExitCode = 0 // successful termination
+	ExitFailure ExitCode = 1 // generic failure
+
+	ExitUsage       ExitCode = 64 // EX_USAGE: command line usage error
+	ExitDataErr     ExitCode = 65 // EX_DATAERR: data format error
+	ExitNoInput     ExitCode = 66 // EX_NOINPUT: cannot open input
+	ExitNoUser      ExitCode = 67 // EX_NOUSER: addressee unknown
+	ExitNoHost      ExitCode = 68 // EX_NOHOST: host name unknown
+	ExitUnavailable ExitCode = 69 // EX_UNAVAILABLE: service unavailable
+	ExitSoftware    ExitCode = 70 // EX_SOFTWARE: internal software error
+	ExitOSErr       ExitCode = 71 // EX_OSERR: system error (e.g., can't fork)
+	ExitOSFile      ExitCode = 72 // EX_OSFILE: critical OS file missing
+	ExitCantCreate  ExitCode = 73 // EX_CANTCREAT: can't create (user) output file
+	ExitIOErr       ExitCode = 74 // EX_IOERR: input/output error
+	ExitTempFail    ExitCode = 75 // EX_TEMPFAIL: temp failure; user is invited to retry
+	ExitProtocol    ExitCode = 76 // EX_PROTOCOL: remote error in protocol
+	ExitNoPerm      ExitCode = 77 // EX_NOPERM: permission denied
+	ExitConfig      ExitCode = 78 // EX_CONFIG: configuration error
+)
+
+// Wrap wraps err with this exit code.
+// The returned error behaves like err when calling Error, [errors.Is] or [errors.As].
+// [CodeFromError] returns this code for the returned error.
+//
+// If err is nil, returns nil.
+func (code ExitCode) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &wrapError{err: err, code: code}
+}
+
+type wrapError struct {
+	err  error
+	code ExitCode
+}
+
+func (err *wrapError) exitCode() ExitCode {
+	return err.code
+}
+
+func (err *wrapError) Error() string {
+	return err.err.Error()
+}
+
+func (err *wrapError) Unwrap() error {
+	return err.err
+}
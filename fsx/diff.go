@@ -0,0 +1,391 @@
+package fsx
+
+//spellchecker:words bytes crypto sha256 errors iter path strings pkglib errorsx
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"strings"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+//spellchecker:words nolint wrapcheck
+
+// ChangeKind describes how an entry differs between two trees.
+type ChangeKind uint8
+
+const (
+	ChangeAdded    ChangeKind = iota // the entry only exists in the second tree
+	ChangeRemoved                    // the entry only exists in the first tree
+	ChangeModified                   // the entry exists in both trees, but differs
+)
+
+func (kind ChangeKind) String() string {
+	switch kind {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Difference is a set of properties in which an entry differs between two trees.
+type Difference uint8
+
+const (
+	DiffType    Difference = 1 << iota // the type of entry differs, e.g. a file was replaced by a directory
+	DiffMode                           // the permission bits differ
+	DiffContent                        // the content of regular files differs
+	DiffTarget                         // the target of symbolic links differs
+)
+
+// String returns a comma-separated list of differences, such as "content, mode".
+func (diff Difference) String() string {
+	names := make([]string, 0, 4)
+	for _, d := range []struct {
+		flag Difference
+		name string
+	}{
+		{DiffType, "type"},
+		{DiffContent, "content"},
+		{DiffMode, "mode"},
+		{DiffTarget, "target"},
+	} {
+		if diff&d.flag != 0 {
+			names = append(names, d.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Change describes a single entry that differs between two trees.
+type Change struct {
+	Path string     // slash-separated path of the entry, relative to the root of both trees
+	Kind ChangeKind // kind of change
+
+	// Diff holds the properties that differ.
+	// It is only set when Kind is [ChangeModified].
+	Diff Difference
+
+	// A and B hold information about the entry in the first and second tree respectively.
+	// They are nil if the entry does not exist in the tree.
+	A, B fs.FileInfo
+}
+
+// String formats the change as a single line.
+// Added entries are prefixed with "+", removed entries with "-" and modified entries with "~".
+func (change Change) String() string {
+	switch change.Kind {
+	case ChangeAdded:
+		return "+ " + change.Path
+	case ChangeRemoved:
+		return "- " + change.Path
+	case ChangeModified:
+		fallthrough
+	default:
+		return "~ " + change.Path + " (" + change.Diff.String() + ")"
+	}
+}
+
+// CompareMode determines how the content of regular files is compared.
+type CompareMode uint8
+
+const (
+	// CompareSizeTime considers files to be different if their size or modification time differ.
+	CompareSizeTime CompareMode = iota
+
+	// CompareHash considers files to be different if their size or the sha256 hash of their content differ.
+	CompareHash
+)
+
+// DiffOptions determine the behavior of [Diff].
+type DiffOptions struct {
+	// Compare determines how the content of regular files is compared.
+	Compare CompareMode
+
+	// IgnoreMode indicates that differences in permission bits should not be reported.
+	IgnoreMode bool
+
+	// Skip, when not nil, is called with the slash-separated path of each entry.
+	// When it returns true, the entry is not compared and, if it is a directory, not traversed.
+	Skip func(path string, isDir bool) bool
+}
+
+// Diff compares the trees a and b and returns an iterator over the entries that differ.
+//
+// Entries are yielded in lexical order, with each directory preceding its content.
+// When a directory only exists in one tree, its content is reported as well.
+// If an error occurs, it is yielded along with an empty change and iteration stops.
+//
+// Symbolic links are compared by target, and are never followed.
+// This requires the file systems to implement [fs.ReadLinkFS], as returned by [os.DirFS].
+func Diff(a, b fs.FS, opts DiffOptions) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		d := differ{a: a, b: b, opts: opts, yield: yield}
+		if err := d.root(); err != nil && !errors.Is(err, errDiffStopped) {
+			yield(Change{}, err)
+		}
+	}
+}
+
+// DiffDirectory is like [Diff], but compares the directories a and b on disk.
+func DiffDirectory(a, b string, opts DiffOptions) iter.Seq2[Change, error] {
+	return Diff(os.DirFS(a), os.DirFS(b), opts)
+}
+
+// WriteDiff writes each change to w, one per line.
+// It returns the first error that occurs during iteration or writing.
+func WriteDiff(w io.Writer, changes iter.Seq2[Change, error]) error {
+	for change, err := range changes {
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, change); err != nil {
+			return fmt.Errorf("failed to write change: %w", err)
+		}
+	}
+	return nil
+}
+
+// errDiffStopped indicates that the consumer of [Diff] stopped iteration.
+var errDiffStopped = errors.New("iteration stopped")
+
+// differ holds the state of [Diff].
+type differ struct {
+	a, b  fs.FS
+	opts  DiffOptions
+	yield func(Change, error) bool
+}
+
+// emit yields change, returning errDiffStopped if iteration should stop.
+func (d *differ) emit(change Change) error {
+	if !d.yield(change, nil) {
+		return errDiffStopped
+	}
+	return nil
+}
+
+// root compares the roots of both trees.
+func (d *differ) root() error {
+	aInfo, err := fs.Stat(d.a, ".")
+	if err != nil {
+		return fmt.Errorf("failed to stat first tree: %w", err)
+	}
+	bInfo, err := fs.Stat(d.b, ".")
+	if err != nil {
+		return fmt.Errorf("failed to stat second tree: %w", err)
+	}
+	return d.both(".", aInfo, bInfo)
+}
+
+// both compares an entry that exists in both trees.
+func (d *differ) both(name string, aInfo, bInfo fs.FileInfo) error {
+	diff, err := d.compare(name, aInfo, bInfo)
+	if err != nil {
+		return err
+	}
+	if diff != 0 {
+		if err := d.emit(Change{Path: name, Kind: ChangeModified, Diff: diff, A: aInfo, B: bInfo}); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case aInfo.IsDir() && bInfo.IsDir():
+		return d.dir(name)
+	case aInfo.IsDir():
+		return d.only(d.a, name, ChangeRemoved, true)
+	case bInfo.IsDir():
+		return d.only(d.b, name, ChangeAdded, true)
+	default:
+		return nil
+	}
+}
+
+// dir compares the content of a directory that exists in both trees.
+func (d *differ) dir(name string) error {
+	aEntries, err := fs.ReadDir(d.a, name)
+	if err != nil {
+		return fmt.Errorf("failed to read directory in first tree: %w", err)
+	}
+	bEntries, err := fs.ReadDir(d.b, name)
+	if err != nil {
+		return fmt.Errorf("failed to read directory in second tree: %w", err)
+	}
+
+	// merge both sorted lists of entries
+	for len(aEntries) > 0 || len(bEntries) > 0 {
+		var aEntry, bEntry fs.DirEntry
+		switch {
+		case len(bEntries) == 0 || (len(aEntries) > 0 && aEntries[0].Name() < bEntries[0].Name()):
+			aEntry, aEntries = aEntries[0], aEntries[1:]
+		case len(aEntries) == 0 || bEntries[0].Name() < aEntries[0].Name():
+			bEntry, bEntries = bEntries[0], bEntries[1:]
+		default:
+			aEntry, aEntries = aEntries[0], aEntries[1:]
+			bEntry, bEntries = bEntries[0], bEntries[1:]
+		}
+
+		if err := d.entry(name, aEntry, bEntry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry compares a single child of the directory parent.
+// Either aEntry or bEntry may be nil.
+func (d *differ) entry(parent string, aEntry, bEntry fs.DirEntry) error {
+	var child string
+	var isDir bool
+	if aEntry != nil {
+		child, isDir = path.Join(parent, aEntry.Name()), aEntry.IsDir()
+	} else {
+		child, isDir = path.Join(parent, bEntry.Name()), bEntry.IsDir()
+	}
+	if d.opts.Skip != nil && d.opts.Skip(child, isDir) {
+		return nil
+	}
+
+	switch {
+	case bEntry == nil:
+		return d.only(d.a, child, ChangeRemoved, false)
+	case aEntry == nil:
+		return d.only(d.b, child, ChangeAdded, false)
+	}
+
+	aInfo, err := aEntry.Info()
+	if err != nil {
+		return fmt.Errorf("failed to stat %q in first tree: %w", child, err)
+	}
+	bInfo, err := bEntry.Info()
+	if err != nil {
+		return fmt.Errorf("failed to stat %q in second tree: %w", child, err)
+	}
+	return d.both(child, aInfo, bInfo)
+}
+
+// only reports the entry name and its content, which only exist in fsys.
+// If contentOnly is true, name itself is not reported.
+func (d *differ) only(fsys fs.FS, name string, kind ChangeKind, contentOnly bool) error {
+	err := fs.WalkDir(fsys, name, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if current == name && contentOnly {
+			return nil
+		}
+		if current != name && d.opts.Skip != nil && d.opts.Skip(current, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %q: %w", current, err)
+		}
+
+		change := Change{Path: current, Kind: kind}
+		if kind == ChangeAdded {
+			change.B = info
+		} else {
+			change.A = info
+		}
+		return d.emit(change)
+	})
+	return err //nolint:wrapcheck // errors are already wrapped
+}
+
+// compare determines how the entry name differs between the two trees.
+func (d *differ) compare(name string, aInfo, bInfo fs.FileInfo) (Difference, error) {
+	if aInfo.Mode().Type() != bInfo.Mode().Type() {
+		return DiffType, nil
+	}
+
+	var diff Difference
+	isLink := aInfo.Mode()&fs.ModeSymlink != 0
+	if !d.opts.IgnoreMode && !isLink && aInfo.Mode() != bInfo.Mode() {
+		diff |= DiffMode
+	}
+
+	switch {
+	case isLink:
+		same, err := d.sameTarget(name)
+		if err != nil {
+			return 0, err
+		}
+		if !same {
+			diff |= DiffTarget
+		}
+	case aInfo.Mode().IsRegular():
+		same, err := d.sameContent(name, aInfo, bInfo)
+		if err != nil {
+			return 0, err
+		}
+		if !same {
+			diff |= DiffContent
+		}
+	}
+	return diff, nil
+}
+
+// sameTarget checks if the symbolic link name has the same target in both trees.
+func (d *differ) sameTarget(name string) (bool, error) {
+	aTarget, err := fs.ReadLink(d.a, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to read link %q in first tree: %w", name, err)
+	}
+	bTarget, err := fs.ReadLink(d.b, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to read link %q in second tree: %w", name, err)
+	}
+	return aTarget == bTarget, nil
+}
+
+// sameContent checks if the regular file name has the same content in both trees.
+func (d *differ) sameContent(name string, aInfo, bInfo fs.FileInfo) (bool, error) {
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+	if d.opts.Compare != CompareHash {
+		return aInfo.ModTime().Equal(bInfo.ModTime()), nil
+	}
+
+	aHash, err := hashFile(d.a, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash %q in first tree: %w", name, err)
+	}
+	bHash, err := hashFile(d.b, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash %q in second tree: %w", name, err)
+	}
+	return bytes.Equal(aHash, bHash), nil
+}
+
+// hashFile computes the sha256 hash of the file name in fsys.
+func hashFile(fsys fs.FS, name string) (sum []byte, e error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer errorsx.Close(file, &e, "file")
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,121 @@
+package fsx_test
+
+//spellchecker:words errors path filepath testing fstest time pkglib
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+func ExampleDiff() {
+	then := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a := fstest.MapFS{
+		"same.txt":     {Data: []byte("same"), Mode: 0o644, ModTime: then},
+		"changed.txt":  {Data: []byte("old"), Mode: 0o644, ModTime: then},
+		"mode.sh":      {Data: []byte("echo"), Mode: 0o644, ModTime: then},
+		"link":         {Data: []byte("same.txt"), Mode: fs.ModeSymlink | 0o777},
+		"removed/file": {Data: []byte("gone"), Mode: 0o644, ModTime: then},
+		"typed":        {Data: []byte("file"), Mode: 0o644, ModTime: then},
+	}
+	b := fstest.MapFS{
+		"same.txt":    {Data: []byte("same"), Mode: 0o644, ModTime: then},
+		"changed.txt": {Data: []byte("new"), Mode: 0o644, ModTime: then},
+		"mode.sh":     {Data: []byte("echo"), Mode: 0o755, ModTime: then},
+		"link":        {Data: []byte("changed.txt"), Mode: fs.ModeSymlink | 0o777},
+		"added.txt":   {Data: []byte("added"), Mode: 0o644, ModTime: then},
+		"typed/file":  {Data: []byte("file"), Mode: 0o644, ModTime: then},
+	}
+
+	if err := fsx.WriteDiff(os.Stdout, fsx.Diff(a, b, fsx.DiffOptions{Compare: fsx.CompareHash})); err != nil {
+		panic(err)
+	}
+
+	// Output: + added.txt
+	// ~ changed.txt (content)
+	// ~ link (target)
+	// ~ mode.sh (mode)
+	// - removed
+	// - removed/file
+	// ~ typed (type)
+	// + typed/file
+}
+
+func TestDiffDirectory(t *testing.T) {
+	t.Parallel()
+
+	then := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := then.Add(time.Hour)
+
+	a, b := t.TempDir(), t.TempDir()
+
+	// write creates a file with the given content and modification time in root.
+	write := func(root, name, content string, mtime time.Time) {
+		t.Helper()
+
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "touched", "content", then)
+	write(b, "touched", "content", later)
+	write(a, "skipped", "a", then)
+	write(b, "skipped", "b", then)
+
+	tests := []struct {
+		name string
+		opts fsx.DiffOptions
+		want []string
+	}{
+		{"size and time", fsx.DiffOptions{}, []string{"~ touched (content)"}},
+		{"hash", fsx.DiffOptions{Compare: fsx.CompareHash}, []string{"~ skipped (content)"}},
+		{"skip", fsx.DiffOptions{Compare: fsx.CompareHash, Skip: func(path string, isDir bool) bool { return path == "skipped" }}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for change, err := range fsx.DiffDirectory(a, b, tt.opts) {
+				if err != nil {
+					t.Fatalf("DiffDirectory() yielded error %v", err)
+				}
+				if change.Path == "." {
+					continue // temporary directories may differ in mode and time
+				}
+				got = append(got, change.String())
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffDirectory_missing(t *testing.T) {
+	t.Parallel()
+
+	var gotErr error
+	for _, err := range fsx.DiffDirectory(t.TempDir(), filepath.Join(t.TempDir(), "missing"), fsx.DiffOptions{}) {
+		gotErr = err
+	}
+	if !errors.Is(gotErr, fs.ErrNotExist) {
+		t.Errorf("got error %v, want %v", gotErr, fs.ErrNotExist)
+	}
+}
@@ -0,0 +1,227 @@
+package fsx
+
+//spellchecker:words context errors strconv strings time pkglib errorsx
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+//spellchecker:words flock nosec
+
+// LockMode determines if a lock is exclusive or shared.
+type LockMode uint8
+
+const (
+	// LockExclusive locks can only be held by a single open file at a time.
+	// They cannot be held while any shared lock is held.
+	LockExclusive LockMode = iota
+
+	// LockShared locks can be held by multiple open files at the same time.
+	LockShared
+)
+
+var (
+	// ErrLocked is returned when a lock is held by somebody else.
+	ErrLocked = errors.New("file is locked")
+
+	// ErrStaleLock is returned by [LockHolder] when a lockfile records a process id, but is not locked.
+	ErrStaleLock = errors.New("stale lock")
+)
+
+// LockedError is returned by [LockPID] when the lock is held by another process.
+// It wraps [ErrLocked].
+type LockedError struct {
+	Path string // path of the lockfile
+	PID  int    // process id recorded in the lockfile, or 0 if unknown
+}
+
+func (le *LockedError) Error() string {
+	if le.PID == 0 {
+		return fmt.Sprintf("%q: %s", le.Path, ErrLocked)
+	}
+	return fmt.Sprintf("%q: %s by process %d", le.Path, ErrLocked, le.PID)
+}
+
+func (le *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// FileLock is an advisory lock on a file.
+// It should be created using [Lock], [TryLock], [LockContext] or [LockPID].
+//
+// Locks are associated with an open file; a process holding a lock does not prevent other processes from opening the file.
+// Locks are automatically released when the process exits.
+type FileLock struct {
+	file *os.File
+	pid  bool // the file records the process id, and should be truncated before unlocking
+}
+
+var _ io.Closer = (*FileLock)(nil)
+
+// Lock acquires a lock on path, creating the file if it does not exist.
+// It blocks until the lock is acquired.
+//
+// Locking is implemented using flock on unix systems other than aix.
+// On other operating systems, an error wrapping [errors.ErrUnsupported] is returned.
+func Lock(path string, mode LockMode) (*FileLock, error) {
+	return lockFile(path, mode, true)
+}
+
+// TryLock is like [Lock], but does not block.
+// If the lock is held by somebody else, it returns an error wrapping [ErrLocked].
+func TryLock(path string, mode LockMode) (*FileLock, error) {
+	return lockFile(path, mode, false)
+}
+
+// lockInterval is the interval in which [LockContext] attempts to acquire a lock.
+const lockInterval = 50 * time.Millisecond
+
+// LockContext is like [Lock], but stops waiting for the lock once ctx is canceled.
+// In that case, it returns an error wrapping both [ErrLocked] and the cause of the cancellation.
+func LockContext(ctx context.Context, path string, mode LockMode) (*FileLock, error) {
+	ticker := time.NewTicker(lockInterval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := TryLock(path, mode)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", err, context.Cause(ctx))
+		case <-ticker.C:
+		}
+	}
+}
+
+// lockFile opens path and locks it.
+func lockFile(path string, mode LockMode, block bool) (lock *FileLock, e error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644) // #nosec G302 G304 -- path is an explicit parameter
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lockfile: %w", err)
+	}
+	defer func() {
+		if e != nil {
+			errorsx.Close(file, &e, "lockfile")
+		}
+	}()
+
+	if err := flock(file, mode, block); err != nil {
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// Path returns the path of the locked file.
+func (lock *FileLock) Path() string {
+	return lock.file.Name()
+}
+
+// Close releases the lock and closes the underlying file.
+// If the lock was created by [LockPID], the recorded process id is removed first.
+func (lock *FileLock) Close() error {
+	var truncateErr error
+	if lock.pid {
+		if err := lock.file.Truncate(0); err != nil {
+			truncateErr = fmt.Errorf("failed to clear process id: %w", err)
+		}
+	}
+
+	var unlockErr error
+	if err := funlock(lock.file); err != nil {
+		unlockErr = err
+	}
+
+	var closeErr error
+	if err := lock.file.Close(); err != nil {
+		closeErr = fmt.Errorf("failed to close lockfile: %w", err)
+	}
+
+	return errorsx.Combine(truncateErr, unlockErr, closeErr)
+}
+
+// LockPID acquires an exclusive lock on path without blocking, and records the id of the current process in it.
+// If the lock is held by another process, it returns a [*LockedError] with the process id recorded by that process.
+//
+// Closing the lock removes the process id from the file, but keeps the file itself.
+// Removing the file would allow two processes to hold locks on different files of the same name.
+func LockPID(path string) (lock *FileLock, e error) {
+	lock, err := TryLock(path, LockExclusive)
+	if errors.Is(err, ErrLocked) {
+		pid, _ := readPID(path) // best effort, the holder may not have written it yet
+		return nil, &LockedError{Path: path, PID: pid}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e != nil {
+			e = errorsx.Combine(e, lock.Close())
+		}
+	}()
+
+	lock.pid = true
+	if err := lock.file.Truncate(0); err != nil {
+		return nil, fmt.Errorf("failed to truncate lockfile: %w", err)
+	}
+	if _, err := lock.file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return nil, fmt.Errorf("failed to write process id: %w", err)
+	}
+	return lock, nil
+}
+
+// LockHolder returns the process id recorded in the lockfile at path, as written by [LockPID].
+//
+// If the file records a process id, but is not locked, the process likely exited without releasing the lock properly.
+// Such a lock is stale, and LockHolder returns the recorded process id along with an error wrapping [ErrStaleLock].
+// A subsequent call to [LockPID] succeeds in this case.
+//
+// If no process id is recorded, it returns 0 and a nil error.
+func LockHolder(path string) (int, error) {
+	pid, err := readPID(path)
+	if err != nil || pid == 0 {
+		return 0, err
+	}
+
+	// probe if the lock is still held
+	lock, err := TryLock(path, LockShared)
+	switch {
+	case errors.Is(err, ErrLocked):
+		return pid, nil
+	case err != nil:
+		return 0, err
+	}
+	if err := lock.Close(); err != nil {
+		return 0, err
+	}
+	return pid, fmt.Errorf("%q: process %d: %w", path, pid, ErrStaleLock)
+}
+
+// readPID reads the process id recorded in the lockfile at path.
+// An empty file records process id 0.
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is an explicit parameter
+	if err != nil {
+		return 0, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse process id: %w", err)
+	}
+	return pid, nil
+}
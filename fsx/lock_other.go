@@ -0,0 +1,22 @@
+//go:build !unix || aix
+
+package fsx
+
+//spellchecker:words errors
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+//spellchecker:words flock funlock
+
+// flock is not supported on this operating system.
+func flock(file *os.File, mode LockMode, block bool) error {
+	return fmt.Errorf("failed to lock file: %w", errors.ErrUnsupported)
+}
+
+// funlock is not supported on this operating system.
+func funlock(file *os.File) error {
+	return fmt.Errorf("failed to unlock file: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,136 @@
+//go:build unix && !aix
+
+package fsx_test
+
+//spellchecker:words context errors path filepath strconv testing time pkglib
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+func TestTryLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "lock")
+
+	exclusive, err := fsx.TryLock(path, fsx.LockExclusive)
+	if err != nil {
+		t.Fatalf("TryLock(exclusive) = %v", err)
+	}
+
+	for _, mode := range []fsx.LockMode{fsx.LockExclusive, fsx.LockShared} {
+		if _, err := fsx.TryLock(path, mode); !errors.Is(err, fsx.ErrLocked) {
+			t.Errorf("TryLock(%d) on exclusive lock = %v, want %v", mode, err, fsx.ErrLocked)
+		}
+	}
+
+	if err := exclusive.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	// multiple shared locks can be held at the same time
+	first, err := fsx.TryLock(path, fsx.LockShared)
+	if err != nil {
+		t.Fatalf("TryLock(shared) = %v", err)
+	}
+	defer func() { _ = first.Close() }()
+
+	second, err := fsx.TryLock(path, fsx.LockShared)
+	if err != nil {
+		t.Fatalf("TryLock(shared) = %v", err)
+	}
+	defer func() { _ = second.Close() }()
+
+	if _, err := fsx.TryLock(path, fsx.LockExclusive); !errors.Is(err, fsx.ErrLocked) {
+		t.Errorf("TryLock(exclusive) on shared lock = %v, want %v", err, fsx.ErrLocked)
+	}
+}
+
+func TestLockContext(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "lock")
+
+	held, err := fsx.Lock(path, fsx.LockExclusive)
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+
+	// waiting for the lock times out
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := fsx.LockContext(ctx, path, fsx.LockExclusive); !errors.Is(err, fsx.ErrLocked) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LockContext() = %v, want %v and %v", err, fsx.ErrLocked, context.DeadlineExceeded)
+	}
+
+	// releasing the lock allows it to be acquired
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = held.Close()
+	}()
+	lock, err := fsx.LockContext(t.Context(), path, fsx.LockExclusive)
+	if err != nil {
+		t.Fatalf("LockContext() = %v", err)
+	}
+	if err := lock.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+}
+
+func TestLockPID(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "pid.lock")
+
+	lock, err := fsx.LockPID(path)
+	if err != nil {
+		t.Fatalf("LockPID() = %v", err)
+	}
+
+	pid, err := fsx.LockHolder(path)
+	if err != nil || pid != os.Getpid() {
+		t.Errorf("LockHolder() = (%d, %v), want (%d, nil)", pid, err, os.Getpid())
+	}
+
+	var locked *fsx.LockedError
+	if _, err := fsx.LockPID(path); !errors.As(err, &locked) || locked.PID != os.Getpid() || !errors.Is(err, fsx.ErrLocked) {
+		t.Errorf("second LockPID() = %v, want a *LockedError with the current process id", err)
+	}
+
+	if err := lock.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if pid, err := fsx.LockHolder(path); pid != 0 || err != nil {
+		t.Errorf("LockHolder() after Close = (%d, %v), want (0, nil)", pid, err)
+	}
+}
+
+func TestLockHolder_stale(t *testing.T) {
+	t.Parallel()
+
+	// simulate a process that exited without releasing the lock
+	path := filepath.Join(t.TempDir(), "pid.lock")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(1<<22)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := fsx.LockHolder(path)
+	if pid != 1<<22 || !errors.Is(err, fsx.ErrStaleLock) {
+		t.Errorf("LockHolder() = (%d, %v), want (%d, %v)", pid, err, 1<<22, fsx.ErrStaleLock)
+	}
+
+	lock, err := fsx.LockPID(path)
+	if err != nil {
+		t.Fatalf("LockPID() on stale lock = %v", err)
+	}
+	if err := lock.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+}
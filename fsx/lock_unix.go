@@ -0,0 +1,49 @@
+//go:build unix && !aix
+
+package fsx
+
+//spellchecker:words errors syscall golang unix
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+//spellchecker:words flock funlock ewouldblock eintr nosec
+
+// flock locks file using the flock system call.
+// If block is false and the lock is held by somebody else, returns an error wrapping [ErrLocked].
+func flock(file *os.File, mode LockMode, block bool) error {
+	how := unix.LOCK_EX
+	if mode == LockShared {
+		how = unix.LOCK_SH
+	}
+	if !block {
+		how |= unix.LOCK_NB
+	}
+
+	for {
+		err := unix.Flock(int(file.Fd()), how) // #nosec G115 -- file descriptors fit into an int
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return fmt.Errorf("%q: %w", file.Name(), ErrLocked)
+		default:
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+	}
+}
+
+// funlock releases a lock acquired by flock.
+func funlock(file *os.File) error {
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_UN); err != nil { // #nosec G115 -- file descriptors fit into an int
+		return fmt.Errorf("failed to unlock file: %w", err)
+	}
+	return nil
+}
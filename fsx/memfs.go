@@ -0,0 +1,529 @@
+package fsx
+
+//spellchecker:words errors path slices strings sync time
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+// MemFS is a [WritableFS] holding all files in memory.
+// It is intended for tests and virtual trees, and must be created using [NewMemFS].
+//
+// MemFS implements [fs.FS], so it can also be used with functions such as [fs.WalkDir] and [Diff].
+// Like for any [fs.FS], names must be valid according to [fs.ValidPath].
+// Methods called with other names return an error wrapping [fs.ErrInvalid].
+//
+// MemFS is safe for concurrent use.
+type MemFS struct {
+	m    sync.RWMutex
+	root *memNode
+}
+
+// NewMemFS creates a new empty [MemFS].
+// The root directory has mode 0755.
+func NewMemFS() *MemFS {
+	return &MemFS{root: &memNode{mode: fs.ModeDir | 0o755, modTime: time.Now(), children: make(map[string]*memNode)}}
+}
+
+// memNode is a single file, directory or symbolic link in a [MemFS].
+type memNode struct {
+	mode       fs.FileMode
+	modTime    time.Time
+	accessTime time.Time
+
+	data     []byte              // content of regular files
+	target   string              // target of symbolic links
+	children map[string]*memNode // children of directories
+}
+
+// info returns information about the node with the given name.
+// Must be called while holding a lock.
+func (node *memNode) info(name string) fs.FileInfo {
+	return &memInfo{name: name, mode: node.mode, size: int64(len(node.data)), modTime: node.modTime, node: node}
+}
+
+// memInfo implements [fs.FileInfo] for a [memNode].
+type memInfo struct {
+	name    string
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+	node    *memNode
+}
+
+func (info *memInfo) Name() string       { return info.name }
+func (info *memInfo) Size() int64        { return info.size }
+func (info *memInfo) Mode() fs.FileMode  { return info.mode }
+func (info *memInfo) ModTime() time.Time { return info.modTime }
+func (info *memInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info *memInfo) Sys() any           { return info.node }
+
+// memPerm returns the permission bits of mode, including special bits.
+func memPerm(mode fs.FileMode) fs.FileMode {
+	return mode & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+// splitPath splits a slash-separated path into its non-trivial components.
+func splitPath(name string) []string {
+	return slices.DeleteFunc(strings.Split(name, "/"), func(part string) bool { return part == "" || part == "." })
+}
+
+// walk resolves name into the directory holding it, and the node itself.
+// If name is not valid according to [fs.ValidPath], returns an error wrapping [fs.ErrInvalid].
+// Symbolic links are followed for all but the last component; the last component is followed iff follow is true.
+// Like in the operating system, ".." components are resolved after following symbolic links.
+//
+// If the parent directory exists, but the last component does not, returns a nil node and no error.
+// If name refers to the root, returns a nil dir and the root node.
+// Must be called while holding a lock.
+func (m *MemFS) walk(op, name string, follow bool) (dir *memNode, base string, node *memNode, err error) {
+	if !fs.ValidPath(name) {
+		return nil, "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	stack := []*memNode{m.root}
+	parts := splitPath(name)
+
+	var links int
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		if part == ".." {
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		current := stack[len(stack)-1]
+		if !current.mode.IsDir() {
+			return nil, "", nil, &fs.PathError{Op: op, Path: name, Err: errNotDir}
+		}
+
+		last := len(parts) == 0
+		child := current.children[part]
+		switch {
+		case child == nil && last:
+			return current, part, nil, nil
+		case child == nil:
+			return nil, "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		case child.mode&fs.ModeSymlink != 0 && (!last || follow):
+			links++
+			if links > maxLinks {
+				return nil, "", nil, &fs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+			}
+			if path.IsAbs(child.target) {
+				stack = stack[:1]
+			}
+			parts = append(splitPath(path.Clean(child.target)), parts...)
+		case last:
+			return current, part, child, nil
+		default:
+			stack = append(stack, child)
+		}
+	}
+
+	// name refers to a directory on the stack
+	node = stack[len(stack)-1]
+	if len(stack) > 1 {
+		dir = stack[len(stack)-2]
+		for name, child := range dir.children {
+			if child == node {
+				base = name
+			}
+		}
+	}
+	return dir, base, node, nil
+}
+
+// lookup is like walk, but returns an error wrapping [fs.ErrNotExist] if the node does not exist.
+func (m *MemFS) lookup(op, name string, follow bool) (dir *memNode, base string, node *memNode, err error) {
+	dir, base, node, err = m.walk(op, name, follow)
+	if err == nil && node == nil {
+		err = &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return dir, base, node, err
+}
+
+// baseName returns the name to be used for information about base.
+func baseName(base string) string {
+	if base == "" {
+		return "."
+	}
+	return base
+}
+
+// Open opens the named file for reading.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	_, base, node, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{fsys: m, node: node, name: baseName(base)}, nil
+}
+
+// Stat returns information about the named file, following symbolic links.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	_, base, node, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(baseName(base)), nil
+}
+
+// Lstat returns information about the named file, without following symbolic links.
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	_, base, node, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(baseName(base)), nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	_, _, node, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return node.entries(), nil
+}
+
+// entries returns the sorted entries of a directory.
+// Must be called while holding a lock.
+func (node *memNode) entries() []fs.DirEntry {
+	names := slices.Sorted(maps.Keys(node.children))
+	entries := make([]fs.DirEntry, len(names))
+	for i, name := range names {
+		entries[i] = fs.FileInfoToDirEntry(node.children[name].info(name))
+	}
+	return entries
+}
+
+// ReadLink returns the target of the named symbolic link.
+func (m *MemFS) ReadLink(name string) (string, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	_, _, node, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return node.target, nil
+}
+
+// create adds a new node to the file system, failing if it already exists.
+func (m *MemFS) create(op, name string, node *memNode) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	dir, base, existing, err := m.walk(op, name, false)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+
+	now := time.Now()
+	node.modTime, node.accessTime = now, now
+	dir.children[base] = node
+	dir.modTime = now
+	return nil
+}
+
+// Mkdir creates a new directory with the given permissions.
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	return m.create("mkdir", name, &memNode{mode: fs.ModeDir | memPerm(perm), children: make(map[string]*memNode)})
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+func (m *MemFS) Symlink(oldname, newname string) error {
+	return m.create("symlink", newname, &memNode{mode: fs.ModeSymlink | fs.ModePerm, target: oldname})
+}
+
+// Create creates or truncates the named file.
+// A newly created file receives the given permissions.
+func (m *MemFS) Create(name string, perm fs.FileMode) (WritableFile, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	dir, base, node, err := m.walk("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case node == nil:
+		node = &memNode{mode: memPerm(perm), accessTime: now}
+		dir.children[base] = node
+		dir.modTime = now
+	case node.mode.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	default:
+		node.data = nil
+	}
+	node.modTime = now
+
+	return &memFile{fsys: m, node: node, name: baseName(base), writable: true}, nil
+}
+
+// Chmod changes the mode of the named file, following symbolic links.
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	_, _, node, err := m.lookup("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode.Type() | memPerm(mode)
+	return nil
+}
+
+// Chtimes changes the access and modification times of the named file, following symbolic links.
+// Like [os.Chtimes], a zero time leaves the corresponding time unchanged.
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	_, _, node, err := m.lookup("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	if !atime.IsZero() {
+		node.accessTime = atime
+	}
+	if !mtime.IsZero() {
+		node.modTime = mtime
+	}
+	return nil
+}
+
+// Rename renames oldname to newname.
+// If newname already exists and is not a directory, it is replaced.
+// An existing empty directory is only replaced by a directory.
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	oldDir, oldBase, node, err := m.lookup("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	if oldDir == nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+
+	newDir, newBase, existing, err := m.walk("rename", newname, false)
+	if err != nil {
+		return err
+	}
+	if newDir == nil {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+
+	switch {
+	case existing == node:
+		return nil
+	case existing == nil:
+	case existing.mode.IsDir() && !node.mode.IsDir():
+		return &fs.PathError{Op: "rename", Path: newname, Err: errIsDir}
+	case existing.mode.IsDir() && len(existing.children) > 0:
+		return &fs.PathError{Op: "rename", Path: newname, Err: errNotEmpty}
+	case !existing.mode.IsDir() && node.mode.IsDir():
+		return &fs.PathError{Op: "rename", Path: newname, Err: errNotDir}
+	}
+
+	// a directory can not be moved into itself
+	if node.contains(newDir) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+
+	delete(oldDir.children, oldBase)
+	newDir.children[newBase] = node
+
+	now := time.Now()
+	oldDir.modTime, newDir.modTime = now, now
+	return nil
+}
+
+// contains checks if other is node or one of its descendants.
+// Must be called while holding a lock.
+func (node *memNode) contains(other *memNode) bool {
+	if node == other {
+		return true
+	}
+	for _, child := range node.children {
+		if child.contains(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes the named file or empty directory.
+func (m *MemFS) Remove(name string) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	dir, base, node, err := m.lookup("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if dir == nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if len(node.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+
+	delete(dir.children, base)
+	dir.modTime = time.Now()
+	return nil
+}
+
+// memFile is an open file of a [MemFS].
+type memFile struct {
+	fsys     *MemFS
+	node     *memNode
+	name     string
+	writable bool
+
+	offset  int64         // offset for reading and writing regular files
+	entries []fs.DirEntry // remaining entries of a directory, once ReadDir has been called
+	listed  bool          // ReadDir has been called
+	closed  bool
+}
+
+func (file *memFile) check(op string) error {
+	if file.closed {
+		return &fs.PathError{Op: op, Path: file.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (file *memFile) Stat() (fs.FileInfo, error) {
+	if err := file.check("stat"); err != nil {
+		return nil, err
+	}
+
+	file.fsys.m.RLock()
+	defer file.fsys.m.RUnlock()
+
+	return file.node.info(file.name), nil
+}
+
+func (file *memFile) Read(p []byte) (int, error) {
+	if err := file.check("read"); err != nil {
+		return 0, err
+	}
+
+	file.fsys.m.RLock()
+	defer file.fsys.m.RUnlock()
+
+	if file.node.mode.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: file.name, Err: errIsDir}
+	}
+	if file.offset >= int64(len(file.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, file.node.data[file.offset:])
+	file.offset += int64(n)
+	return n, nil
+}
+
+func (file *memFile) Write(p []byte) (int, error) {
+	if err := file.check("write"); err != nil {
+		return 0, err
+	}
+	if !file.writable {
+		return 0, &fs.PathError{Op: "write", Path: file.name, Err: fs.ErrPermission}
+	}
+
+	file.fsys.m.Lock()
+	defer file.fsys.m.Unlock()
+
+	end := file.offset + int64(len(p))
+	if grow := end - int64(len(file.node.data)); grow > 0 {
+		file.node.data = append(file.node.data, make([]byte, grow)...)
+	}
+	copy(file.node.data[file.offset:], p)
+	file.offset = end
+	file.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (file *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if err := file.check("readdir"); err != nil {
+		return nil, err
+	}
+
+	if !file.listed {
+		file.fsys.m.RLock()
+		isDir := file.node.mode.IsDir()
+		if isDir {
+			file.entries = file.node.entries()
+		}
+		file.fsys.m.RUnlock()
+
+		if !isDir {
+			return nil, &fs.PathError{Op: "readdir", Path: file.name, Err: errNotDir}
+		}
+		file.listed = true
+	}
+
+	if n <= 0 {
+		entries := file.entries
+		file.entries = nil
+		return entries, nil
+	}
+	if len(file.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(file.entries))
+	entries := file.entries[:n]
+	file.entries = file.entries[n:]
+	return entries, nil
+}
+
+func (file *memFile) Close() error {
+	if err := file.check("close"); err != nil {
+		return err
+	}
+	file.closed = true
+	return nil
+}
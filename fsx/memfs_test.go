@@ -0,0 +1,209 @@
+package fsx_test
+
+//spellchecker:words errors strings testing fstest time pkglib
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+// makeMemFS creates a [fsx.MemFS] holding a few files, directories and links.
+func makeMemFS(t *testing.T) *fsx.MemFS {
+	t.Helper()
+
+	fsys := fsx.NewMemFS()
+	for _, dir := range []string{"dir", "dir/sub"} {
+		if err := fsys.Mkdir(dir, 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{"file": "hello", "dir/sub/nested": "world"} {
+		file, err := fsys.Create(name, 0o640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(file, content); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{"link": "dir/sub/nested", "dir/up": "..", "absolute": "/dir/sub", "broken": "missing"} {
+		if err := fsys.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fsys
+}
+
+func TestMemFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := makeMemFS(t)
+
+	// walking does not follow links
+	var walked []string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, path+":"+d.Type().String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir() = %v", err)
+	}
+	want := ".:d---------, absolute:L---------, broken:L---------, dir:d---------, dir/sub:d---------, dir/sub/nested:----------, dir/up:L---------, file:----------, link:L---------"
+	if got := strings.Join(walked, ", "); got != want {
+		t.Errorf("WalkDir() visited %q, want %q", got, want)
+	}
+
+	// links are resolved, also for absolute targets
+	for name, want := range map[string]string{
+		"link":                  "world",
+		"dir/up/dir/sub/nested": "world",
+		"absolute/nested":       "world",
+	} {
+		if got, err := fs.ReadFile(fsys, name); err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q) = (%q, %v), want %q", name, got, err, want)
+		}
+	}
+
+	// invalid names are rejected
+	for _, name := range []string{"/file", "./file", "dir/sub/../../file", "file/", ""} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q) = %v, want %v", name, err, fs.ErrInvalid)
+		}
+		if err := fsys.Mkdir(name, 0o755); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Mkdir(%q) = %v, want %v", name, err, fs.ErrInvalid)
+		}
+	}
+	if _, err := fsys.Stat("broken"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(broken) = %v, want %v", err, fs.ErrNotExist)
+	}
+	if info, err := fsys.Lstat("broken"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Lstat(broken) = (%v, %v), want a link", info, err)
+	}
+
+	// modes and times are kept exactly
+	if err := fsys.Chmod("file", fs.ModeSetuid|0o711); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	if err := fsys.Chtimes("link", time.Time{}, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fsys.Stat("file"); err != nil || info.Mode() != fs.ModeSetuid|0o711 {
+		t.Errorf("Stat(file) = (%v, %v), want mode %v", info, err, fs.ModeSetuid|0o711)
+	}
+	if info, err := fsys.Stat("dir/sub/nested"); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("Stat(dir/sub/nested) = (%v, %v), want modification time %v", info, err, mtime)
+	}
+}
+
+func TestMemFS_fstest(t *testing.T) {
+	t.Parallel()
+
+	fsys := makeMemFS(t)
+	if err := fsys.Remove("broken"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(fsys, "file", "dir/sub/nested", "link", "absolute", "dir/up"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemFS_modify(t *testing.T) {
+	t.Parallel()
+
+	fsys := makeMemFS(t)
+
+	// creating existing entries fails
+	if err := fsys.Mkdir("dir", 0o755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Mkdir(dir) = %v, want %v", err, fs.ErrExist)
+	}
+	if err := fsys.Symlink("target", "file"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Symlink(file) = %v, want %v", err, fs.ErrExist)
+	}
+	if err := fsys.Mkdir("missing/dir", 0o755); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Mkdir(missing/dir) = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	// non-empty directories can not be removed, or moved into themselves
+	if err := fsys.Remove("dir"); err == nil {
+		t.Error("Remove(dir) succeeded, want an error")
+	}
+	if err := fsys.Rename("dir", "dir/sub/moved"); err == nil {
+		t.Error("Rename(dir, dir/sub/moved) succeeded, want an error")
+	}
+
+	// renaming replaces files and removes the old name
+	if err := fsys.Rename("dir/sub/nested", "file"); err != nil {
+		t.Fatalf("Rename() = %v", err)
+	}
+	if got, err := fs.ReadFile(fsys, "file"); err != nil || string(got) != "world" {
+		t.Errorf("ReadFile(file) = (%q, %v), want %q", got, err, "world")
+	}
+	if _, err := fsys.Lstat("dir/sub/nested"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Lstat(dir/sub/nested) = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	// removing a link removes the link, not its target
+	if err := fsys.Remove("absolute"); err != nil {
+		t.Fatalf("Remove(absolute) = %v", err)
+	}
+	if exists, err := fsx.ExistsFS(fsys, "dir/sub"); err != nil || !exists {
+		t.Errorf("ExistsFS(dir/sub) = (%v, %v), want true", exists, err)
+	}
+}
+
+func TestSameFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := makeMemFS(t)
+
+	tests := []struct {
+		path1, path2 string
+		want         bool
+	}{
+		{"file", "dir/up/file", true},
+		{"link", "dir/sub/nested", true},
+		{"file", "link", false},
+		{"dir/up/missing", "missing", true},
+		{"missing", "other", false},
+	}
+	for _, tt := range tests {
+		if got := fsx.SameFS(fsys, tt.path1, tt.path2); got != tt.want {
+			t.Errorf("SameFS(%q, %q) = %v, want %v", tt.path1, tt.path2, got, tt.want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	fsys := fsx.ReadOnly(fstest.MapFS{
+		"dir/file": &fstest.MapFile{Data: []byte("content"), Mode: 0o644},
+	})
+
+	if isDir, err := fsx.IsDirectoryFS(fsys, "/dir", false); err != nil || !isDir {
+		t.Errorf("IsDirectoryFS() = (%v, %v), want true", isDir, err)
+	}
+	if exists, err := fsx.ExistsFS(fsys, "dir/missing"); err != nil || exists {
+		t.Errorf("ExistsFS() = (%v, %v), want false", exists, err)
+	}
+	if err := fsys.Mkdir("dir/new", 0o755); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Mkdir() = %v, want %v", err, fs.ErrPermission)
+	}
+	if _, err := fsys.Create("dir/file", 0o644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Create() = %v, want %v", err, fs.ErrPermission)
+	}
+}
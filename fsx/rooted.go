@@ -0,0 +1,140 @@
+package fsx
+
+//spellchecker:words errors path filepath
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//spellchecker:words openat openat2 nosec
+
+// Rooted provides access to files within a single directory tree.
+// Untrusted paths passed to its methods can not refer to locations outside of the tree.
+// It must be created using [OpenRooted].
+//
+// All paths passed to methods of Rooted are relative to the root directory.
+// Paths that are absolute or contain ".." components leaving the root return an error wrapping [ErrEscapesRoot].
+// Symbolic links are followed, but may not leave the root either.
+// Unlike [SecureJoin], absolute link targets and ".." components of link targets are not resolved relative to the root.
+// Links that would leave the root return an error instead.
+//
+// On Linux, paths are resolved by the kernel using openat2 with RESOLVE_BENEATH.
+// This prevents escaping the root even if the tree is modified concurrently.
+// Elsewhere, or when openat2 is not available, [os.Root] is used.
+// In that case, only errors for lexically escaping paths are guaranteed to wrap [ErrEscapesRoot].
+//
+// Rooted is safe for concurrent use.
+type Rooted struct {
+	root *os.Root
+	dir  *os.File // file descriptor of the root, used for openat2
+}
+
+// OpenRooted opens the directory dir for use as a root.
+// The returned Rooted should be closed when no longer needed.
+func OpenRooted(dir string) (*Rooted, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open root: %w", err)
+	}
+
+	file, err := os.Open(dir) // #nosec G304 -- dir is an explicit parameter
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to open root: %w", err), root.Close())
+	}
+
+	return &Rooted{root: root, dir: file}, nil
+}
+
+// Name returns the name of the root directory, as passed to [OpenRooted].
+func (r *Rooted) Name() string {
+	return r.root.Name()
+}
+
+// Close closes the root.
+// Files opened from the root remain usable.
+func (r *Rooted) Close() error {
+	var rootErr, dirErr error
+	if err := r.root.Close(); err != nil {
+		rootErr = fmt.Errorf("failed to close root: %w", err)
+	}
+	if err := r.dir.Close(); err != nil {
+		dirErr = fmt.Errorf("failed to close root: %w", err)
+	}
+	return errors.Join(rootErr, dirErr)
+}
+
+// check checks that name does not lexically escape the root.
+func (r *Rooted) check(op, name string) error {
+	if !filepath.IsLocal(name) && filepath.Clean(name) != "." {
+		return &fs.PathError{Op: op, Path: name, Err: ErrEscapesRoot}
+	}
+	return nil
+}
+
+// Open opens the named file for reading.
+func (r *Rooted) Open(name string) (*os.File, error) {
+	return r.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file, like [os.Create].
+func (r *Rooted) Create(name string) (*os.File, error) {
+	return r.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// OpenFile opens the named file, like [os.OpenFile].
+func (r *Rooted) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if err := r.check("open", name); err != nil {
+		return nil, err
+	}
+
+	if file, ok, err := r.openat2(name, flag, perm); ok {
+		return file, err
+	}
+
+	file, err := r.root.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+// Stat returns information about the named file, following symbolic links.
+func (r *Rooted) Stat(name string) (fs.FileInfo, error) {
+	if err := r.check("stat", name); err != nil {
+		return nil, err
+	}
+
+	if info, ok, err := r.statat2(name); ok {
+		return info, err
+	}
+
+	info, err := r.root.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, nil
+}
+
+// Mkdir creates the named directory, like [os.Mkdir].
+func (r *Rooted) Mkdir(name string, perm fs.FileMode) error {
+	if err := r.check("mkdir", name); err != nil {
+		return err
+	}
+
+	if ok, err := r.mkdirat2(name, perm); ok {
+		return err
+	}
+
+	if err := r.root.Mkdir(name, perm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
+// FS returns a file system for the tree, like [os.DirFS].
+func (r *Rooted) FS() fs.FS {
+	return r.root.FS()
+}
@@ -0,0 +1,114 @@
+package fsx
+
+//spellchecker:words errors path filepath sync atomic syscall pkglib errorsx golang unix
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"golang.org/x/sys/unix"
+)
+
+//spellchecker:words openat openat2 statat2 mkdirat mkdirat2 magiclinks cloexec enosys exdev eintr eagain isuid isgid isvtx nosec
+
+// openat2Unsupported is set once openat2 is known to be unsupported by the kernel.
+var openat2Unsupported atomic.Bool
+
+// openat opens name beneath the root using openat2.
+// If openat2 is not supported, returns ok = false.
+func (r *Rooted) openat(op, name string, flag int, perm fs.FileMode) (fd int, ok bool, err error) {
+	if openat2Unsupported.Load() {
+		return -1, false, nil
+	}
+
+	how := unix.OpenHow{
+		Flags:   uint64(flag) | unix.O_CLOEXEC, // #nosec G115 -- flags are non-negative
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	if flag&os.O_CREATE != 0 {
+		how.Mode = uint64(syscallMode(perm))
+	}
+
+	for {
+		fd, err := unix.Openat2(int(r.dir.Fd()), name, &how) // #nosec G115 -- file descriptors fit into an int
+		switch {
+		case err == nil:
+			return fd, true, nil
+		case errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN):
+			continue
+		case errors.Is(err, syscall.ENOSYS):
+			openat2Unsupported.Store(true)
+			return -1, false, nil
+		case errors.Is(err, syscall.EXDEV):
+			return -1, true, &fs.PathError{Op: op, Path: name, Err: ErrEscapesRoot}
+		default:
+			return -1, true, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+}
+
+// openat2 opens the named file using openat2.
+// If openat2 is not supported, returns ok = false.
+func (r *Rooted) openat2(name string, flag int, perm fs.FileMode) (*os.File, bool, error) {
+	fd, ok, err := r.openat("open", name, flag, perm)
+	if !ok {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to open file: %w", err)
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(r.Name(), name)), true, nil
+}
+
+// statat2 stats the named file using openat2.
+// If openat2 is not supported, returns ok = false.
+func (r *Rooted) statat2(name string) (info fs.FileInfo, ok bool, e error) {
+	file, ok, err := r.openat2(name, unix.O_PATH, 0)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	defer errorsx.Close(file, &e, "file")
+
+	info, err = file.Stat()
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, true, nil
+}
+
+// mkdirat2 creates the named directory, resolving its parent using openat2.
+// If openat2 is not supported, returns ok = false.
+func (r *Rooted) mkdirat2(name string, perm fs.FileMode) (ok bool, e error) {
+	name = filepath.Clean(name)
+
+	parent, ok, err := r.openat2(filepath.Dir(name), unix.O_PATH|unix.O_DIRECTORY, 0)
+	if !ok || err != nil {
+		return ok, err
+	}
+	defer errorsx.Close(parent, &e, "parent directory")
+
+	if err := unix.Mkdirat(int(parent.Fd()), filepath.Base(name), syscallMode(perm)); err != nil { // #nosec G115 -- file descriptors fit into an int
+		return true, fmt.Errorf("failed to create directory: %w", &fs.PathError{Op: "mkdir", Path: name, Err: err})
+	}
+	return true, nil
+}
+
+// syscallMode converts perm into a mode for system calls.
+func syscallMode(perm fs.FileMode) uint32 {
+	mode := uint32(perm.Perm())
+	if perm&fs.ModeSetuid != 0 {
+		mode |= unix.S_ISUID
+	}
+	if perm&fs.ModeSetgid != 0 {
+		mode |= unix.S_ISGID
+	}
+	if perm&fs.ModeSticky != 0 {
+		mode |= unix.S_ISVTX
+	}
+	return mode
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package fsx
+
+import (
+	"io/fs"
+	"os"
+)
+
+//spellchecker:words openat2 statat2 mkdirat2
+
+// openat2 is not supported on this operating system, and always returns ok = false.
+func (r *Rooted) openat2(name string, flag int, perm fs.FileMode) (file *os.File, ok bool, err error) {
+	return nil, false, nil
+}
+
+// statat2 is not supported on this operating system, and always returns ok = false.
+func (r *Rooted) statat2(name string) (info fs.FileInfo, ok bool, err error) {
+	return nil, false, nil
+}
+
+// mkdirat2 is not supported on this operating system, and always returns ok = false.
+func (r *Rooted) mkdirat2(name string, perm fs.FileMode) (ok bool, err error) {
+	return false, nil
+}
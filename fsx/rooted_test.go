@@ -0,0 +1,128 @@
+//go:build unix
+
+package fsx_test
+
+//spellchecker:words errors path filepath testing pkglib
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+// makeRoot creates a directory with links pointing inside and outside of it.
+func makeRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, dir := range []string{"a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "b", "file"), []byte("content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for link, target := range map[string]string{
+		"a/up":       "..",
+		"a/absolute": "/a/b",
+		"c/escape":   "../../../../../../etc",
+		"c/relative": "../a/b/file",
+		"loop":       "loop",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSecureJoin(t *testing.T) {
+	t.Parallel()
+
+	root := makeRoot(t)
+
+	tests := []struct {
+		unsafe  string
+		want    string
+		wantErr bool
+	}{
+		{"a/b/file", "a/b/file", false},
+		{"../../../etc/passwd", "etc/passwd", false},
+		{"/a/../../b", "b", false},
+		{"a/up/c", "c", false},
+		{"a/absolute/file", "a/b/file", false},
+		{"c/escape/passwd", "etc/passwd", false},
+		{"c/relative", "a/b/file", false},
+		{"missing/../a", "a", false},
+		{"loop", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unsafe, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := fsx.SecureJoin(root, tt.unsafe)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SecureJoin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if want := filepath.Join(root, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("SecureJoin() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRooted(t *testing.T) {
+	t.Parallel()
+
+	root, err := fsx.OpenRooted(makeRoot(t))
+	if err != nil {
+		t.Fatalf("OpenRooted() = %v", err)
+	}
+	defer func() {
+		if err := root.Close(); err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	}()
+
+	// paths inside the root work
+	if info, err := root.Stat("c/relative"); err != nil || info.Size() != int64(len("content")) {
+		t.Errorf("Stat() = (%v, %v)", info, err)
+	}
+	if err := root.Mkdir("c/new", 0o750); err != nil {
+		t.Errorf("Mkdir() = %v", err)
+	}
+	file, err := root.Create("c/new/file")
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(missing) = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	// paths escaping the root do not
+	for _, name := range []string{"../outside", "/etc/passwd", "a/b/../../.."} {
+		if _, err := root.Open(name); !errors.Is(err, fsx.ErrEscapesRoot) {
+			t.Errorf("Open(%q) = %v, want %v", name, err, fsx.ErrEscapesRoot)
+		}
+		if err := root.Mkdir(name, 0o750); !errors.Is(err, fsx.ErrEscapesRoot) {
+			t.Errorf("Mkdir(%q) = %v, want %v", name, err, fsx.ErrEscapesRoot)
+		}
+	}
+	// unlike with SecureJoin, links leaving the root are rejected rather than resolved inside of it
+	for _, name := range []string{"c/escape/passwd", "a/absolute/file"} {
+		if _, err := root.Stat(name); err == nil {
+			t.Errorf("Stat(%q) succeeded, want an error", name)
+		}
+	}
+}
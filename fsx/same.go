@@ -2,14 +2,22 @@ package fsx
 
 //spellchecker:words path filepath
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 )
 
 // Same checks if path1 and path2 refer to the same path.
 // If both paths exist, they are compared using [os.Same].
-// If both files do not exist, the paths are first compared syntactically and then via recursion on [filepath.Dir].
+// If both files do not exist, the paths are first compared syntactically.
+// Otherwise, they are split using [filepath.Split], and are the same if they have the same base name and their cleaned directories are the same.
+// In particular, a path with a trailing separator has an empty base name.
 func Same(path1, path2 string) bool {
+	return SameFS(OS, path1, path2)
+}
+
+// SameFS is like [Same], but operates on fsys.
+func SameFS(fsys WritableFS, path1, path2 string) bool {
 	// if the paths are identical, then we don't need to check anything.
 	// and in particular, we don't need to do any expensive stat calls.
 	if filepath.Clean(path1) == filepath.Clean(path2) {
@@ -17,12 +25,13 @@ func Same(path1, path2 string) bool {
 	}
 
 	// initial attempt: check if directly
-	same, certain := couldBeSameFile(path1, path2)
+	same, certain := couldBeSameFile(fsys, path1, path2)
 	if certain {
 		return same
 	}
 
-	// second attempt: find the directory names and base paths
+	// second attempt: find the directory names and base paths.
+	// a trailing separator results in an empty base name.
 	d1, n1 := filepath.Split(path1)
 	d2, n2 := filepath.Split(path2)
 
@@ -33,7 +42,8 @@ func Same(path1, path2 string) bool {
 
 	// compare the base names!
 	{
-		same, _ := couldBeSameFile(d1, d2)
+		// clean the directories, as not every fsys accepts trailing separators
+		same, _ := couldBeSameFile(fsys, filepath.Clean(d1), filepath.Clean(d2))
 		return same
 	}
 }
@@ -45,15 +55,15 @@ func Same(path1, path2 string) bool {
 //
 // same indicates if they might be the same file.
 // authoritative indicates if the result is authoritative.
-func couldBeSameFile(path1, path2 string) (same, authoritative bool) {
+func couldBeSameFile(fsys WritableFS, path1, path2 string) (same, authoritative bool) {
 	{
-		info1, notExists1, err1 := stat(path1, true)
-		info2, notExists2, err2 := stat(path2, true)
+		info1, notExists1, err1 := stat(fsys, path1, true)
+		info2, notExists2, err2 := stat(fsys, path2, true)
 
 		// both files exist => check using os.SameFile
 		// the result is always authoritative
 		if err1 == nil && err2 == nil {
-			same = os.SameFile(info1, info2)
+			same = sameFile(info1, info2)
 			authoritative = true
 			return
 		}
@@ -71,8 +81,8 @@ func couldBeSameFile(path1, path2 string) (same, authoritative bool) {
 
 	{
 		// resolve paths absolutely
-		rpath1, err1 := filepath.Abs(path1)
-		rpath2, err2 := filepath.Abs(path2)
+		rpath1, err1 := abs(fsys, path1)
+		rpath2, err2 := abs(fsys, path2)
 
 		// if either path could not be resolved absolutely
 		// fallback to just using clean!
@@ -89,3 +99,21 @@ func couldBeSameFile(path1, path2 string) (same, authoritative bool) {
 		return
 	}
 }
+
+// sameFile checks if fi1 and fi2 describe the same file.
+// It supports information returned by the operating system and by [MemFS].
+func sameFile(fi1, fi2 fs.FileInfo) bool {
+	if node1, ok := fi1.Sys().(*memNode); ok {
+		node2, ok := fi2.Sys().(*memNode)
+		return ok && node1 == node2
+	}
+	return os.SameFile(fi1, fi2)
+}
+
+// abs returns an absolute representation of path within fsys.
+func abs(fsys WritableFS, path string) (string, error) {
+	if _, ok := fsys.(osFS); ok {
+		return filepath.Abs(path) //nolint:wrapcheck // error is not returned to callers
+	}
+	return "/" + validPath(path), nil
+}
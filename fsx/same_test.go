@@ -79,6 +79,10 @@ func TestSameFile(t *testing.T) {
 		{"non-identical existing directories (2)", d1, d2, false},
 		{"non-identical existing directories (3)", d1, alsoD2, false},
 		{"non-identical existing directories (4)", alsoD2, d1, false},
+
+		{"identical non-existing files in linked directories", f2, filepath.Join(alsoD2, "f2"), true},
+		{"non-existing file with trailing separator (1)", f2 + string(filepath.Separator), filepath.Join(f2, "f2"), false},
+		{"non-existing file with trailing separator (2)", filepath.Join(f2, "f2"), f2 + string(filepath.Separator), false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
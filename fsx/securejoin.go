@@ -0,0 +1,95 @@
+package fsx
+
+//spellchecker:words errors path filepath strings
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrEscapesRoot is returned when a path refers to a location outside of a root directory.
+var ErrEscapesRoot = errors.New("path escapes root")
+
+// errTooManyLinks is returned when resolving a path encounters too many symbolic links.
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// maxLinks is the maximum number of symbolic links followed when resolving a path.
+const maxLinks = 255
+
+// SecureJoin joins root and unsafePath, such that the result is guaranteed to be inside root.
+//
+// Unlike [filepath.Join], symbolic links inside root are resolved component by component,
+// treating root as the file system root.
+// This means that ".." components and absolute link targets can never leave root,
+// and are instead resolved relative to root, just like they would be in a chroot.
+// Components that do not exist are joined lexically.
+//
+// The returned path is only guaranteed to be inside root at the time SecureJoin is called.
+// If the file system may be modified concurrently, use [Rooted] instead.
+// Note that [Rooted] rejects symbolic links leaving the root, instead of resolving them relative to root.
+func SecureJoin(root, unsafePath string) (string, error) {
+	// virtual is the resolved path inside root.
+	// It is always absolute, so that ".." can never go above it.
+	virtual := string(filepath.Separator)
+
+	var links int
+	remaining := unsafePath
+	for remaining != "" {
+		// take the next component
+		var component string
+		component, remaining, _ = strings.Cut(remaining, string(filepath.Separator))
+		if filepath.Separator != '/' {
+			var rest string
+			component, rest, _ = strings.Cut(component, "/")
+			if rest != "" {
+				remaining = rest + string(filepath.Separator) + remaining
+			}
+		}
+
+		if component == "" || component == "." {
+			continue
+		}
+
+		next := filepath.Join(virtual, component)
+		if component == ".." {
+			virtual = next
+			continue
+		}
+
+		// check if we have a link
+		info, err := os.Lstat(filepath.Join(root, next))
+		if errors.Is(err, fs.ErrNotExist) {
+			virtual = next
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %q: %w", next, err)
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			virtual = next
+			continue
+		}
+
+		// resolve the link
+		links++
+		if links > maxLinks {
+			return "", fmt.Errorf("failed to resolve %q: %w", unsafePath, errTooManyLinks)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", fmt.Errorf("failed to read link %q: %w", next, err)
+		}
+
+		// absolute targets are relative to the root
+		if filepath.IsAbs(target) || strings.HasPrefix(target, string(filepath.Separator)) {
+			virtual = string(filepath.Separator)
+			target = strings.TrimPrefix(target, filepath.VolumeName(target))
+		}
+		remaining = target + string(filepath.Separator) + remaining
+	}
+
+	return filepath.Join(root, virtual), nil
+}
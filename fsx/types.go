@@ -4,15 +4,14 @@ package fsx
 import (
 	"errors"
 	"io/fs"
-	"os"
 )
 
-// stat performs stat on path, following links if requested.
-func stat(path string, follow bool) (info fs.FileInfo, isNotNotExists bool, err error) {
+// stat performs stat on path in fsys, following links if requested.
+func stat(fsys WritableFS, path string, follow bool) (info fs.FileInfo, isNotNotExists bool, err error) {
 	if follow {
-		info, err = os.Stat(path)
+		info, err = fsys.Stat(path)
 	} else {
-		info, err = os.Lstat(path)
+		info, err = fsys.Lstat(path)
 	}
 	isNotNotExists = err != nil && !errors.Is(err, fs.ErrNotExist)
 	return
@@ -23,7 +22,12 @@ func stat(path string, follow bool) (info fs.FileInfo, isNotNotExists bool, err
 //
 // If an error occurs, returns false, err.
 func Exists(path string) (bool, error) {
-	_, other, err := stat(path, false)
+	return ExistsFS(OS, path)
+}
+
+// ExistsFS is like [Exists], but operates on fsys.
+func ExistsFS(fsys WritableFS, path string) (bool, error) {
+	_, other, err := stat(fsys, path, false)
 	if other {
 		return false, err
 	}
@@ -33,7 +37,12 @@ func Exists(path string) (bool, error) {
 // IsDirectory checks if the provided path exists and is a directory.
 // IsDirectory follows links iff followLinks is true.
 func IsDirectory(path string, followLinks bool) (bool, error) {
-	info, other, err := stat(path, followLinks)
+	return IsDirectoryFS(OS, path, followLinks)
+}
+
+// IsDirectoryFS is like [IsDirectory], but operates on fsys.
+func IsDirectoryFS(fsys WritableFS, path string, followLinks bool) (bool, error) {
+	info, other, err := stat(fsys, path, followLinks)
 	if other {
 		return false, err
 	}
@@ -43,7 +52,12 @@ func IsDirectory(path string, followLinks bool) (bool, error) {
 // IsRegular checks if the provided path exists and is a directory.
 // IsRegular follows links iff followLinks is true.
 func IsRegular(path string, followLinks bool) (bool, error) {
-	info, other, err := stat(path, followLinks)
+	return IsRegularFS(OS, path, followLinks)
+}
+
+// IsRegularFS is like [IsRegular], but operates on fsys.
+func IsRegularFS(fsys WritableFS, path string, followLinks bool) (bool, error) {
+	info, other, err := stat(fsys, path, followLinks)
 	if other {
 		return false, err
 	}
@@ -53,7 +67,12 @@ func IsRegular(path string, followLinks bool) (bool, error) {
 // IsLink checks if the provided path exists and is a symlink.
 // An invalid link is considered a link.
 func IsLink(path string) (bool, error) {
-	info, other, err := stat(path, false)
+	return IsLinkFS(OS, path)
+}
+
+// IsLinkFS is like [IsLink], but operates on fsys.
+func IsLinkFS(fsys WritableFS, path string) (bool, error) {
+	info, other, err := stat(fsys, path, false)
 	if other {
 		return false, err
 	}
@@ -0,0 +1,287 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words archive compress gzip errors path filepath slices strings pkglib errorsx
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+//spellchecker:words nosec
+
+// ArchiveFormat is a format of an archive.
+type ArchiveFormat uint8
+
+const (
+	// FormatAuto determines the format from the extension of the archive path.
+	FormatAuto ArchiveFormat = iota
+
+	FormatTar   // uncompressed tar archive
+	FormatTarGz // gzip-compressed tar archive
+	FormatZip   // zip archive
+)
+
+func (format ArchiveFormat) String() string {
+	switch format {
+	case FormatAuto:
+		return "auto"
+	case FormatTar:
+		return "tar"
+	case FormatTarGz:
+		return "tar.gz"
+	case FormatZip:
+		return "zip"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownArchiveFormat is returned when the format of an archive can not be determined.
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+// ArchiveFormatOf determines the format of an archive from the extension of path.
+// Recognized extensions are ".tar", ".tar.gz", ".tgz" and ".zip".
+func ArchiveFormatOf(path string) (ArchiveFormat, error) {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return FormatTar, nil
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	default:
+		return FormatAuto, fmt.Errorf("%q: %w", path, ErrUnknownArchiveFormat)
+	}
+}
+
+// ArchiveEntry describes a single entry written to or extracted from an archive.
+type ArchiveEntry struct {
+	Name string      // slash-separated name of the entry within the archive
+	Path string      // path of the entry on disk
+	Mode fs.FileMode // mode of the entry
+	Size int64       // size of the content of regular files
+}
+
+// ArchiveOptions determine the behavior of [CreateArchive] and [WriteArchive].
+type ArchiveOptions struct {
+	// Format is the format of the archive to create.
+	Format ArchiveFormat
+
+	// Exclude holds glob patterns of entries that should not be added to the archive.
+	// Patterns are interpreted as in [CopyOptions.Exclude].
+	Exclude []string
+
+	// OnEntry, when not nil, is called for each entry once it has been added to the archive.
+	OnEntry func(entry ArchiveEntry)
+}
+
+// CreateArchive creates an archive at path holding the content of the directory src.
+// If opts.Format is [FormatAuto], the format is determined using [ArchiveFormatOf].
+//
+// The archive is written atomically using [CreateAtomic] and has mode [DefaultFilePerm].
+func CreateArchive(path, src string, opts ArchiveOptions) (e error) {
+	if opts.Format == FormatAuto {
+		format, err := ArchiveFormatOf(path)
+		if err != nil {
+			return err
+		}
+		opts.Format = format
+	}
+
+	af, err := CreateAtomic(path, DefaultFilePerm, AtomicOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		e = errorsx.Combine(e, af.Abort())
+	}()
+
+	if err := WriteArchive(af, src, opts); err != nil {
+		return err
+	}
+	return af.Close()
+}
+
+// WriteArchive writes an archive holding the content of the directory src to w.
+// The format must be given explicitly in opts.
+//
+// Entry names are slash-separated and relative to src.
+// Regular files, directories and symbolic links are added, together with their modes and modification times.
+// Symbolic links are not followed, and other types of files are skipped.
+func WriteArchive(w io.Writer, src string, opts ArchiveOptions) (e error) {
+	var aw archiveWriter
+	switch opts.Format {
+	case FormatTar:
+		aw = tarWriter{tar.NewWriter(w)}
+	case FormatTarGz:
+		zw := gzip.NewWriter(w)
+		defer errorsx.Close(zw, &e, "gzip writer")
+		aw = tarWriter{tar.NewWriter(zw)}
+	case FormatZip:
+		aw = zipWriter{zip.NewWriter(w)}
+	case FormatAuto:
+		fallthrough
+	default:
+		return fmt.Errorf("%s: %w", opts.Format, ErrUnknownArchiveFormat)
+	}
+	defer errorsx.Close(aw, &e, "archive writer")
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("failed to determine relative path: %w", err)
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+
+		if slices.ContainsFunc(opts.Exclude, func(pattern string) bool { return matchPattern(pattern, name) }) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %q: %w", path, err)
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() && info.Mode()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		if err := aw.add(name, path, info); err != nil {
+			return fmt.Errorf("failed to add %q: %w", name, err)
+		}
+
+		if opts.OnEntry != nil {
+			entry := ArchiveEntry{Name: name, Path: path, Mode: info.Mode()}
+			if info.Mode().IsRegular() {
+				entry.Size = info.Size()
+			}
+			opts.OnEntry(entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// archiveWriter writes entries into a specific archive format.
+type archiveWriter interface {
+	io.Closer
+
+	// add adds the file at path with the given name and info to the archive.
+	add(name, path string, info fs.FileInfo) error
+}
+
+type tarWriter struct{ *tar.Writer }
+
+func (tw tarWriter) add(name, path string, info fs.FileInfo) error {
+	target, err := linkTarget(path, info)
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, target)
+	if err != nil {
+		return fmt.Errorf("failed to create header: %w", err)
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	// PAX headers preserve sub-second modification times
+	header.Format = tar.FormatPAX
+	header.AccessTime = accessTime(info)
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	return writeContent(tw, path)
+}
+
+type zipWriter struct{ *zip.Writer }
+
+func (zw zipWriter) add(name, path string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to create header: %w", err)
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if info.Mode().IsRegular() {
+		header.Method = zip.Deflate
+	}
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		return writeContent(w, path)
+	case info.Mode()&fs.ModeSymlink != 0:
+		// zip stores the target of a symbolic link as its content
+		target, err := linkTarget(path, info)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, target); err != nil {
+			return fmt.Errorf("failed to write link target: %w", err)
+		}
+	}
+	return nil
+}
+
+// linkTarget returns the target of path if it is a symbolic link, and the empty string otherwise.
+func linkTarget(path string, info fs.FileInfo) (string, error) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return "", nil
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read link: %w", err)
+	}
+	return target, nil
+}
+
+// writeContent writes the content of the file at path to w.
+func writeContent(w io.Writer, path string) (e error) {
+	file, err := os.Open(path) // #nosec G304 -- path is found by walking the source directory
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer errorsx.Close(file, &e, "file")
+
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+	return nil
+}
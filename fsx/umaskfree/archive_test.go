@@ -0,0 +1,223 @@
+//go:build unix
+
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words archive bytes errors path filepath strings testing time pkglib umaskfree
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx"
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+)
+
+func TestCreateArchive(t *testing.T) {
+	t.Parallel()
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	src := makeTree(t, map[string]string{
+		"exec":       "#!/bin/sh",
+		"sub/file":   "file",
+		"skip/entry": "excluded",
+	})
+	for name, mode := range map[string]fs.FileMode{"exec": 0o751, "sub/file": 0o640, "sub": 0o705} {
+		if err := os.Chmod(filepath.Join(src, name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("sub/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"exec", "sub/file", "sub"} {
+		if err := os.Chtimes(filepath.Join(src, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ext := range []string{".tar", ".tar.gz", ".zip"} {
+		t.Run(ext, func(t *testing.T) {
+			t.Parallel()
+
+			archive := filepath.Join(t.TempDir(), "archive"+ext)
+			var added []string
+			err := umaskfree.CreateArchive(archive, src, umaskfree.ArchiveOptions{
+				Exclude: []string{"skip"},
+				OnEntry: func(entry umaskfree.ArchiveEntry) { added = append(added, entry.Name) },
+			})
+			if err != nil {
+				t.Fatalf("CreateArchive() = %v", err)
+			}
+			if got, want := strings.Join(added, ","), "exec,link,sub,sub/file"; got != want {
+				t.Errorf("CreateArchive() added %q, want %q", got, want)
+			}
+
+			dst := filepath.Join(t.TempDir(), "dst")
+			var extracted int64
+			err = umaskfree.ExtractArchive(dst, archive, umaskfree.ExtractOptions{
+				OnEntry: func(entry umaskfree.ArchiveEntry) { extracted += entry.Size },
+			})
+			if err != nil {
+				t.Fatalf("ExtractArchive() = %v", err)
+			}
+			if want := int64(len("#!/bin/sh") + len("file")); extracted != want {
+				t.Errorf("ExtractArchive() extracted %d bytes, want %d", extracted, want)
+			}
+
+			assertFile(t, filepath.Join(dst, "exec"), "#!/bin/sh", 0o751)
+			assertFile(t, filepath.Join(dst, "sub", "file"), "file", 0o640)
+			if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "sub/file" {
+				t.Errorf("link has target (%q, %v), want %q", target, err, "sub/file")
+			}
+			if _, err := os.Lstat(filepath.Join(dst, "skip")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("excluded directory was extracted: %v", err)
+			}
+			for name, mode := range map[string]fs.FileMode{"exec": 0o751, "sub": fs.ModeDir | 0o705} {
+				info, err := os.Stat(filepath.Join(dst, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode() != mode {
+					t.Errorf("%q has mode %v, want %v", name, info.Mode(), mode)
+				}
+				if !info.ModTime().Equal(mtime) {
+					t.Errorf("%q has modification time %v, want %v", name, info.ModTime(), mtime)
+				}
+			}
+		})
+	}
+}
+
+// tarEntry is an entry of a tar archive for testing.
+type tarEntry struct {
+	header  tar.Header
+	content string
+}
+
+// makeTar creates a tar archive holding the given entries.
+func makeTar(t *testing.T, entries ...tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	tw := tar.NewWriter(&buffer)
+	for _, entry := range entries {
+		if entry.header.Mode == 0 {
+			entry.header.Mode = 0o644
+		}
+		entry.header.Size = int64(len(entry.content))
+		if err := tw.WriteHeader(&entry.header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buffer
+}
+
+func TestExtractTar_escape(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"../evil", "/absolute", "sub/../../evil"} {
+		archive := makeTar(t, tarEntry{header: tar.Header{Name: name, Typeflag: tar.TypeReg}, content: "evil"})
+		if err := umaskfree.ExtractTar(t.TempDir(), archive, umaskfree.ExtractOptions{}); !errors.Is(err, fsx.ErrEscapesRoot) {
+			t.Errorf("ExtractTar(%q) = %v, want %v", name, err, fsx.ErrEscapesRoot)
+		}
+	}
+
+	// files are never written through links leaving the destination
+	outside := t.TempDir()
+	dst := t.TempDir()
+	archive := makeTar(t,
+		tarEntry{header: tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside}},
+		tarEntry{header: tar.Header{Name: "link/file", Typeflag: tar.TypeReg}, content: "evil"},
+		tarEntry{header: tar.Header{Name: "link", Typeflag: tar.TypeReg}, content: "replaced"},
+	)
+	if err := umaskfree.ExtractTar(dst, archive, umaskfree.ExtractOptions{}); err != nil {
+		t.Fatalf("ExtractTar() = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "file")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file was written outside of destination: %v", err)
+	}
+	assertFile(t, filepath.Join(dst, filepath.FromSlash(outside), "file"), "evil", 0o644)
+	assertFile(t, filepath.Join(dst, "link"), "replaced", 0o644)
+}
+
+func TestExtractTar_replacedDirectory(t *testing.T) {
+	t.Parallel()
+
+	outside := t.TempDir()
+	if err := os.Chmod(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory that is replaced by a link must not have its mode and times set through the link
+	then := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	archive := makeTar(t,
+		tarEntry{header: tar.Header{Name: "dir", Typeflag: tar.TypeDir, Mode: 0o700, ModTime: then}},
+		tarEntry{header: tar.Header{Name: "dir", Typeflag: tar.TypeSymlink, Linkname: outside}},
+	)
+	dst := t.TempDir()
+	if err := umaskfree.ExtractTar(dst, archive, umaskfree.ExtractOptions{}); err != nil {
+		t.Fatalf("ExtractTar() = %v", err)
+	}
+
+	after, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Mode() != before.Mode() || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("directory outside of destination changed from %v (%v) to %v (%v)", before.Mode(), before.ModTime(), after.Mode(), after.ModTime())
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "dir")); err != nil || target != outside {
+		t.Errorf("Readlink() = %q, %v, want %q", target, err, outside)
+	}
+}
+
+func TestExtractTar_limits(t *testing.T) {
+	t.Parallel()
+
+	archive := func() *bytes.Buffer {
+		return makeTar(t,
+			tarEntry{header: tar.Header{Name: "a", Typeflag: tar.TypeReg}, content: strings.Repeat("a", 100)},
+			tarEntry{header: tar.Header{Name: "b", Typeflag: tar.TypeReg}, content: strings.Repeat("b", 100)},
+		)
+	}
+
+	tests := []struct {
+		name    string
+		opts    umaskfree.ExtractOptions
+		wantErr bool
+	}{
+		{"no limits", umaskfree.ExtractOptions{}, false},
+		{"enough bytes", umaskfree.ExtractOptions{MaxBytes: 200}, false},
+		{"too many bytes", umaskfree.ExtractOptions{MaxBytes: 150}, true},
+		{"enough entries", umaskfree.ExtractOptions{MaxEntries: 2}, false},
+		{"too many entries", umaskfree.ExtractOptions{MaxEntries: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := umaskfree.ExtractTar(t.TempDir(), archive(), tt.opts)
+			if tt.wantErr != errors.Is(err, umaskfree.ErrArchiveLimit) || (!tt.wantErr && err != nil) {
+				t.Errorf("ExtractTar() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
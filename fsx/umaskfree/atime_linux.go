@@ -0,0 +1,21 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words syscall time
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+//spellchecker:words atim
+
+// accessTime returns the access time stored in info.
+// If it is not available, returns the modification time.
+func accessTime(info fs.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(stat.Atim.Unix())
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words time
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime returns the modification time, as access times are not portably available.
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,178 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words errors path filepath pkglib errorsx
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+//spellchecker:words fsync
+
+// AtomicOptions determine the behavior of [WriteFileAtomic] and [CreateAtomic].
+type AtomicOptions struct {
+	// PreserveMode indicates that the mode of an existing destination file should be used
+	// instead of the mode passed as an argument.
+	PreserveMode bool
+
+	// PreserveOwner indicates that the owner and group of an existing destination file should be kept.
+	// This may require elevated privileges and is a no-op on operating systems without file ownership.
+	PreserveOwner bool
+}
+
+// AtomicFile is a file that atomically replaces a destination path when closed.
+// It should be created using [CreateAtomic].
+//
+// Data is written to a temporary file in the same directory as the destination.
+// Upon [AtomicFile.Close], the temporary file is synced and renamed over the destination.
+// Other processes thus either observe the old or the new content of the destination, but never partial content.
+//
+// If the destination is a symlink, the symlink itself is replaced by a regular file; its target remains unchanged.
+// The mode and owner preserved by [AtomicOptions] are however those of the target.
+type AtomicFile struct {
+	path string   // destination path
+	file *os.File // temporary file
+	done bool     // Close or Abort has been called
+}
+
+var errAtomicFileDone = errors.New("atomic file already closed")
+
+// CreateAtomic creates a new [AtomicFile] that replaces path once closed.
+// The file is created with exactly the given mode, regardless of the umask.
+func CreateAtomic(path string, perm fs.FileMode, opts AtomicOptions) (af *AtomicFile, e error) {
+	// find the existing file (if any)
+	existing, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		existing = nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to stat destination: %w", err)
+	}
+
+	if opts.PreserveMode && existing != nil {
+		perm = existing.Mode()
+	}
+
+	// create a temporary file next to the destination
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if e == nil {
+			return
+		}
+		e = errorsx.Combine(e, discardTemp(file))
+	}()
+
+	// set the owner and exact mode.
+	// chown may clear the setuid and setgid bits, so it must happen first.
+	if opts.PreserveOwner && existing != nil {
+		if err := chownLike(file, existing); err != nil {
+			return nil, fmt.Errorf("failed to chown temporary file: %w", err)
+		}
+	}
+	if err := file.Chmod(perm); err != nil {
+		return nil, fmt.Errorf("failed to chmod temporary file: %w", err)
+	}
+
+	return &AtomicFile{path: path, file: file}, nil
+}
+
+// Name returns the name of the destination file.
+func (af *AtomicFile) Name() string {
+	return af.path
+}
+
+// Write writes data to the temporary file.
+func (af *AtomicFile) Write(data []byte) (int, error) {
+	if af.done {
+		return 0, errAtomicFileDone
+	}
+	n, err := af.file.Write(data)
+	if err != nil {
+		return n, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return n, nil
+}
+
+// Close syncs the temporary file to disk and renames it over the destination.
+// It then syncs the parent directory, to ensure the rename is durable.
+//
+// If any step fails, the temporary file is removed and the destination remains unchanged.
+func (af *AtomicFile) Close() error {
+	if af.done {
+		return errAtomicFileDone
+	}
+	af.done = true
+
+	if err := af.file.Sync(); err != nil {
+		return errorsx.Combine(fmt.Errorf("failed to sync temporary file: %w", err), discardTemp(af.file))
+	}
+	if err := af.file.Close(); err != nil {
+		return errorsx.Combine(fmt.Errorf("failed to close temporary file: %w", err), removeTemp(af.file))
+	}
+	if err := os.Rename(af.file.Name(), af.path); err != nil {
+		return errorsx.Combine(fmt.Errorf("failed to rename temporary file: %w", err), removeTemp(af.file))
+	}
+	if err := syncDir(filepath.Dir(af.path)); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// Abort discards the temporary file and leaves the destination unchanged.
+// Calling Abort after Close or Abort is a no-op.
+//
+// Abort is intended to be deferred after [CreateAtomic]:
+//
+//	af, err := CreateAtomic(path, perm, opts)
+//	if err != nil { /* ... */ }
+//	defer af.Abort()
+func (af *AtomicFile) Abort() error {
+	if af.done {
+		return nil
+	}
+	af.done = true
+
+	return discardTemp(af.file)
+}
+
+// discardTemp closes and removes the temporary file.
+func discardTemp(file *os.File) error {
+	var closeErr error
+	if err := file.Close(); err != nil {
+		closeErr = fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	return errorsx.Combine(closeErr, removeTemp(file))
+}
+
+// removeTemp removes the temporary file.
+func removeTemp(file *os.File) error {
+	if err := os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("failed to remove temporary file: %w", err)
+	}
+	return nil
+}
+
+// WriteFileAtomic is like [WriteFile], but atomically replaces the destination.
+// See [AtomicFile] for details.
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode, opts AtomicOptions) (e error) {
+	af, err := CreateAtomic(path, perm, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		e = errorsx.Combine(e, af.Abort())
+	}()
+
+	if _, err := af.Write(data); err != nil {
+		return err
+	}
+	return af.Close()
+}
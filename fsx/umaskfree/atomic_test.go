@@ -0,0 +1,102 @@
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words path filepath testing pkglib umaskfree
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	// new file should get the exact mode, regardless of umask
+	if err := umaskfree.WriteFileAtomic(path, []byte("first"), 0o777, umaskfree.AtomicOptions{}); err != nil {
+		t.Fatalf("WriteFileAtomic() = %v", err)
+	}
+	assertFile(t, path, "first", 0o777)
+
+	// replacing the file without preserving the mode
+	if err := umaskfree.WriteFileAtomic(path, []byte("second"), 0o640, umaskfree.AtomicOptions{}); err != nil {
+		t.Fatalf("WriteFileAtomic() = %v", err)
+	}
+	assertFile(t, path, "second", 0o640)
+
+	// replacing the file and preserving the mode
+	if err := umaskfree.WriteFileAtomic(path, []byte("third"), 0o600, umaskfree.AtomicOptions{PreserveMode: true, PreserveOwner: true}); err != nil {
+		t.Fatalf("WriteFileAtomic() = %v", err)
+	}
+	assertFile(t, path, "third", 0o640)
+
+	// no temporary files should be left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d directory entries, want 1", len(entries))
+	}
+}
+
+func TestAtomicFile_Abort(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	if err := umaskfree.WriteFile(path, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	af, err := umaskfree.CreateAtomic(path, 0o644, umaskfree.AtomicOptions{})
+	if err != nil {
+		t.Fatalf("CreateAtomic() = %v", err)
+	}
+	if _, err := af.Write([]byte("partial")); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if err := af.Abort(); err != nil {
+		t.Fatalf("Abort() = %v", err)
+	}
+	if err := af.Close(); err == nil {
+		t.Error("Close() after Abort() did not return an error")
+	}
+
+	assertFile(t, path, "original", 0o644)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d directory entries, want 1", len(entries))
+	}
+}
+
+// assertFile asserts that the file at path has the given content and mode.
+func assertFile(t *testing.T, path string, content string, mode fs.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %q: %v", path, err)
+	}
+	if got := info.Mode().Perm(); got != mode {
+		t.Errorf("%q has mode %v, want %v", path, got, mode)
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- test file
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", path, err)
+	}
+	if string(data) != content {
+		t.Errorf("%q has content %q, want %q", path, string(data), content)
+	}
+}
@@ -0,0 +1,40 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words context errors pkglib
+import (
+	"context"
+	"errors"
+
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+//spellchecker:words reflink reflinks ficlone btrfs
+
+// CloneMode determines how the content of regular files is copied.
+type CloneMode uint8
+
+const (
+	// CloneNever always copies file data in user space.
+	// It is the default.
+	CloneNever CloneMode = iota
+
+	// CloneAuto clones files using copy-on-write reflinks where the file system supports it.
+	// Otherwise, it falls back to copying the data inside the kernel, and finally to copying it in user space.
+	CloneAuto
+
+	// CloneAlways requires files to be cloned using copy-on-write reflinks.
+	// If the file system does not support this, an error wrapping [ErrCloneUnsupported] is returned.
+	CloneAlways
+)
+
+// ErrCloneUnsupported is returned when a file cannot be cloned and the mode is [CloneAlways].
+var ErrCloneUnsupported = errors.New("copy-on-write cloning not supported")
+
+// CopyFileWith is like [CopyFile], but copies the content of regular files as determined by mode.
+//
+// Reflinks are only supported on Linux (using the FICLONE ioctl), on file systems such as btrfs or xfs.
+// On Linux, copying inside the kernel uses copy_file_range.
+func CopyFileWith(dst, src string, mode CloneMode) error {
+	return copyFile(context.Background(), fsx.OS, dst, src, mode, nil)
+}
@@ -0,0 +1,69 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words context errors syscall golang unix
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+//spellchecker:words ficlone enosys exdev eopnotsupp einval enotty nosec
+
+// cloneFile clones the content of src into dst using the FICLONE ioctl.
+// It returns false if the file system does not support cloning.
+func cloneFile(dst, src *os.File) (bool, error) {
+	err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())) // #nosec G115 -- file descriptors fit into an int
+	switch {
+	case err == nil:
+		return true, nil
+	case unsupported(err):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to clone file: %w", err)
+	}
+}
+
+// copyRangeChunk is the maximum number of bytes copied by a single call to copy_file_range.
+const copyRangeChunk = 8 << 20
+
+// copyRange copies data from the current offset of src to the current offset of dst using copy_file_range.
+// It stops once it reaches the end of src, or when the kernel cannot copy any more data.
+// The file offsets are updated, so that any remaining data can be copied in user space.
+//
+// The copy is aborted once ctx is canceled.
+// progress, when not nil, is called with the number of bytes copied after each chunk.
+func copyRange(ctx context.Context, dst, src *os.File, progress func(n int64)) error {
+	for {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
+		n, err := unix.CopyFileRange(int(src.Fd()), nil, int(dst.Fd()), nil, copyRangeChunk, 0) // #nosec G115 -- file descriptors fit into an int
+		switch {
+		case err != nil && unsupported(err):
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to copy file range: %w", err)
+		case n == 0:
+			return nil
+		}
+
+		if progress != nil {
+			progress(int64(n))
+		}
+	}
+}
+
+// unsupported checks if err indicates that an operation is not supported for a pair of files.
+func unsupported(err error) bool {
+	return errors.Is(err, syscall.ENOSYS) ||
+		errors.Is(err, syscall.EXDEV) ||
+		errors.Is(err, syscall.EOPNOTSUPP) ||
+		errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOTTY)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words context
+import (
+	"context"
+	"os"
+)
+
+// cloneFile is not supported on operating systems other than Linux.
+func cloneFile(dst, src *os.File) (bool, error) {
+	return false, nil
+}
+
+// copyRange is not supported on operating systems other than Linux.
+// It copies no data, so that all data is copied in user space.
+func copyRange(ctx context.Context, dst, src *os.File, progress func(n int64)) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words errors path filepath strings testing pkglib umaskfree
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+)
+
+//spellchecker:words hardlinks
+
+func TestCopyFileWith(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("content", 100_000)
+
+	for _, mode := range []umaskfree.CloneMode{umaskfree.CloneAuto, umaskfree.CloneNever, umaskfree.CloneAlways} {
+		src := makeTree(t, map[string]string{"file": content})
+		dst := filepath.Join(t.TempDir(), "file")
+
+		err := umaskfree.CopyFileWith(dst, filepath.Join(src, "file"), mode)
+
+		// cloning is not supported by every file system
+		if mode == umaskfree.CloneAlways && errors.Is(err, umaskfree.ErrCloneUnsupported) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("CopyFileWith(%d) = %v", mode, err)
+		}
+		assertFile(t, dst, content, 0o644)
+	}
+}
+
+func TestCopyDirectoryWith_hardlinks(t *testing.T) {
+	t.Parallel()
+
+	for _, parallel := range []bool{false, true} {
+		src := makeTree(t, map[string]string{"a": "linked", "other": "other"})
+		for _, name := range []string{"b", "sub/c"} {
+			if err := umaskfree.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Link(filepath.Join(src, "a"), filepath.Join(src, name)); err != nil {
+				t.Skipf("hard links not supported: %v", err)
+			}
+		}
+		dst := filepath.Join(t.TempDir(), "dst")
+
+		opts := umaskfree.CopyOptions{Hardlinks: true}
+		var err error
+		if parallel {
+			_, err = umaskfree.CopyDirectoryParallel(t.Context(), dst, src, umaskfree.ParallelOptions{CopyOptions: opts})
+		} else {
+			err = umaskfree.CopyDirectoryWith(dst, src, opts)
+		}
+		if err != nil {
+			t.Fatalf("copy (parallel = %v) = %v", parallel, err)
+		}
+
+		a, err := os.Stat(filepath.Join(dst, "a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"b", "sub/c"} {
+			info, err := os.Stat(filepath.Join(dst, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !os.SameFile(a, info) {
+				t.Errorf("parallel = %v: %q is not linked to %q", parallel, name, "a")
+			}
+			assertFile(t, filepath.Join(dst, name), "linked", 0o644)
+		}
+
+		other, err := os.Stat(filepath.Join(dst, "other"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if os.SameFile(a, other) {
+			t.Errorf("parallel = %v: unrelated files were linked", parallel)
+		}
+	}
+}
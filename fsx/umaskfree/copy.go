@@ -1,34 +1,45 @@
 //spellchecker:words umaskfree
 package umaskfree
 
-//spellchecker:words errors path filepath pkglib errorsx
+//spellchecker:words errors pkglib errorsx
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
 
 	"go.tkw01536.de/pkglib/errorsx"
 	"go.tkw01536.de/pkglib/fsx"
 )
 
-//spellchecker:words nolint wrapcheck
-
 var ErrCopySameFile = errors.New(`src and dst must be different`)
 
 // CopyFile copies a file from src to dst.
 // When src points to a symbolic link, will copy the symbolic link.
 //
 // When dst and src are the same file, returns [ErrCopySameFile].
-func CopyFile(dst, src string) (e error) {
-	if fsx.Same(src, dst) {
+//
+// The content is always copied in user space; use [CopyFileWith] to clone files using copy-on-write.
+func CopyFile(dst, src string) error {
+	return CopyFileFS(fsx.OS, dst, src)
+}
+
+// CopyFileFS is like [CopyFile], but operates on fsys.
+func CopyFileFS(fsys fsx.WritableFS, dst, src string) error {
+	return copyFile(context.Background(), fsys, dst, src, CloneNever, nil)
+}
+
+// copyFile implements [CopyFileFS] and [CopyFileWith].
+// The copy is aborted once ctx is canceled.
+// progress, when not nil, is called with the number of bytes written after each write.
+func copyFile(ctx context.Context, fsys fsx.WritableFS, dst, src string, mode CloneMode, progress func(n int64)) (e error) {
+	if fsx.SameFS(fsys, src, dst) {
 		return ErrCopySameFile
 	}
 
 	// open the source
-	srcFile, err := os.Open(src) // #nosec G304 -- src is an explicit parameter
+	srcFile, err := fsys.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %w", err)
 	}
@@ -41,49 +52,113 @@ func CopyFile(dst, src string) (e error) {
 	}
 
 	// open or create the destination
-	dstFile, err := Create(dst, srcStat.Mode())
+	dstFile, err := fsys.Create(dst, srcStat.Mode())
 	if err != nil {
-		return err
+		return err //nolint:wrapcheck // errors returned by WritableFS are already wrapped
 	}
 	defer errorsx.Close(dstFile, &e, "destination file")
 
-	// and do the copy!
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
+	return copyContent(ctx, dst, dstFile, srcFile, srcStat.Size(), mode, progress)
+}
+
+// copyContent copies the content of the source file of the given size into the empty file dstFile named dst.
+// Files can only be cloned, or copied inside the kernel, if both are [*os.File]s.
+// See [copyFile] for the remaining parameters.
+func copyContent(ctx context.Context, dst string, dstFile io.Writer, srcFile io.Reader, size int64, mode CloneMode, progress func(n int64)) error {
+	// try to clone the file, or copy it within the kernel
+	if mode != CloneNever {
+		dstOS, dstOK := dstFile.(*os.File)
+		srcOS, srcOK := srcFile.(*os.File)
+
+		var cloned bool
+		if dstOK && srcOK {
+			var err error
+			cloned, err = cloneFile(dstOS, srcOS)
+			if err != nil {
+				return err
+			}
+		}
+		if cloned {
+			if progress != nil {
+				progress(size)
+			}
+			return nil
+		}
+		if mode == CloneAlways {
+			return fmt.Errorf("%q: %w", dst, ErrCloneUnsupported)
+		}
+
+		if dstOK && srcOK {
+			if err := copyRange(ctx, dstOS, srcOS, progress); err != nil {
+				return err
+			}
+		}
+	}
+
+	// and copy whatever remains!
+	// only wrap the source when needed, as this prevents io.Copy from using optimized system calls.
+	reader := srcFile
+	if ctx.Done() != nil || progress != nil {
+		reader = &progressReader{ctx: ctx, reader: srcFile, progress: progress}
+	}
+	if _, err := io.Copy(dstFile, reader); err != nil {
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
 	return nil
 }
 
+// progressReader wraps a reader to check for cancellation and report progress.
+type progressReader struct {
+	ctx      context.Context //nolint:containedctx // only used for the duration of a single copy
+	reader   io.Reader
+	progress func(n int64)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := context.Cause(pr.ctx); err != nil {
+		return 0, err
+	}
+	n, err := pr.reader.Read(p)
+	if n > 0 && pr.progress != nil {
+		pr.progress(int64(n))
+	}
+	return n, err //nolint:wrapcheck // must return unwrapped io.EOF
+}
+
 // CopyLink copies a link from src to dst.
 // If dst already exists, it is deleted and then re-created.
 func CopyLink(dst, src string) error {
+	return CopyLinkFS(fsx.OS, dst, src)
+}
+
+// CopyLinkFS is like [CopyLink], but operates on fsys.
+func CopyLinkFS(fsys fsx.WritableFS, dst, src string) error {
 	// if they're the same file that is an error
-	if fsx.Same(dst, src) {
+	if fsx.SameFS(fsys, dst, src) {
 		return ErrCopySameFile
 	}
 
 	// read the link target
-	target, err := os.Readlink(src)
+	target, err := fsys.ReadLink(src)
 	if err != nil {
 		return fmt.Errorf("failed to read source link: %w", err)
 	}
 
 	// delete it if it already exists
 	{
-		exists, err := fsx.Exists(dst)
+		exists, err := fsx.ExistsFS(fsys, dst)
 		if err != nil {
 			return fmt.Errorf("failed to check destination file: %w", err)
 		}
 		if exists {
-			if err := os.Remove(dst); err != nil {
+			if err := fsys.Remove(dst); err != nil {
 				return fmt.Errorf("failed to remove destination file: %w", err)
 			}
 		}
 	}
 
 	// make the symbolic link!
-	if err := os.Symlink(target, dst); err != nil {
+	if err := fsys.Symlink(target, dst); err != nil {
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
 	return nil
@@ -97,74 +172,20 @@ var ErrDstFile = errors.New("dst is a file")
 // When a directory already exists, additional files are not deleted.
 //
 // onCopy, when not nil, is called for each file or directory being copied.
+// It receives the destination and source path of the entry.
+//
+// CopyDirectory is equivalent to [CopyDirectoryWith] with default options.
 func CopyDirectory(dst, src string, onCopy func(dst, src string)) error {
-	// sanity checks
-	if fsx.Same(src, dst) {
-		return ErrCopySameFile
-	}
+	return CopyDirectoryFS(fsx.OS, dst, src, onCopy)
+}
 
-	// check that the destination is a regular file
-	{
-		isRegular, err := fsx.IsRegular(dst, true)
-		if err != nil {
-			return fmt.Errorf("failed to check destination file: %w", err)
-		}
-		if isRegular {
-			return ErrDstFile
-		}
+// CopyDirectoryFS is like [CopyDirectory], but operates on fsys.
+func CopyDirectoryFS(fsys fsx.WritableFS, dst, src string, onCopy func(dst, src string)) error {
+	var opts CopyOptions
+	if onCopy != nil {
+		opts.OnEntry = func(entry CopyEntry) { onCopy(entry.Dst, entry.Src) }
 	}
-
-	//nolint:wrapcheck // no need to wrap
-	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
-		// someone previously returned an error
-		if err != nil {
-			return err
-		}
-
-		// determine the real target path
-		var relPath string
-		relPath, err = filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-		dst := filepath.Join(dst, relPath)
-
-		// call the hook
-		if onCopy != nil {
-			onCopy(dst, src)
-		}
-
-		// stat the directory, so that we can get mode, and info later!
-		info, err := d.Info()
-		if err != nil {
-			return err
-		}
-
-		// if we have a symbolic link, copy the link!
-		if info.Mode()&fs.ModeSymlink != 0 {
-			return CopyLink(dst, path)
-		}
-
-		// if we got a file, we should copy it normally
-		if !d.IsDir() {
-			return CopyFile(dst, path)
-		}
-
-		// create the directory, but ignore an error if the directory already exists.
-		// this is so that we can copy one tree into another tree.
-		err = Mkdir(dst, info.Mode())
-		if errors.Is(err, fs.ErrExist) {
-			isDir, isDirE := fsx.IsDirectory(dst, false)
-			if isDirE != nil {
-				return isDirE
-			}
-			if isDir {
-				err = nil
-			}
-		}
-
-		return err
-	})
+	return CopyDirectoryWithFS(fsys, dst, src, opts)
 }
 
-//spellchecker:words nosec
+//spellchecker:words nolint containedctx wrapcheck
@@ -1,13 +1,15 @@
 //spellchecker:words umaskfree
 package umaskfree
 
-//spellchecker:words path filepath syscall
+//spellchecker:words path filepath syscall pkglib
 import (
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"syscall"
+
+	"go.tkw01536.de/pkglib/fsx"
 )
 
 //spellchecker:words nolint wrapcheck
@@ -17,21 +19,18 @@ const DefaultDirPerm fs.FileMode = fs.ModeDir | fs.ModePerm
 
 // Mkdir is like [os.Mkdir].
 func Mkdir(path string, perm fs.FileMode) error {
-	targetPerm := fs.ModeDir | perm
-
-	if err := os.Mkdir(path, targetPerm); err != nil {
-		return fmt.Errorf("failed to make directory: %w", err)
-	}
-	if err := os.Chmod(path, targetPerm); err != nil {
-		return fmt.Errorf("failed to set mode: %w", err)
-	}
-	return nil
+	return fsx.OS.Mkdir(path, fs.ModeDir|perm) //nolint:wrapcheck // errors returned by WritableFS are already wrapped
 }
 
 // MkdirAll is like [os.MkdirAll].
 func MkdirAll(path string, perm fs.FileMode) error {
+	return MkdirAllFS(fsx.OS, path, perm)
+}
+
+// MkdirAllFS is like [MkdirAll], but operates on fsys.
+func MkdirAllFS(fsys fsx.WritableFS, path string, perm fs.FileMode) error {
 	// Fast path: if we can tell whether path is a directory or file, stop with success or error.
-	dir, err := os.Stat(path)
+	dir, err := fsys.Stat(path)
 	if err == nil {
 		if dir.IsDir() {
 			return nil
@@ -58,22 +57,22 @@ func MkdirAll(path string, perm fs.FileMode) error {
 	// If there is a parent directory, and it is not the volume name,
 	// recurse to ensure parent directory exists.
 	if parent := path[:i]; len(parent) > len(filepath.VolumeName(path)) {
-		err = MkdirAll(parent, perm)
+		err = MkdirAllFS(fsys, parent, perm)
 		if err != nil {
 			return fmt.Errorf("failed to make directories: %w", err)
 		}
 	}
 
 	// Parent now exists; invoke Mkdir and use its result.
-	err = Mkdir(path, perm)
+	err = fsys.Mkdir(path, fs.ModeDir|perm)
 	if err != nil {
 		// Handle arguments like "foo/." by
 		// double-checking that directory doesn't exist.
-		dir, err1 := os.Lstat(path)
+		dir, err1 := fsys.Lstat(path)
 		if err1 == nil && dir.IsDir() {
 			return nil
 		}
-		return err
+		return err //nolint:wrapcheck // errors returned by WritableFS are already wrapped
 	}
 	return nil
 }
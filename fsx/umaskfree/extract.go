@@ -0,0 +1,397 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words archive bufio compress gzip errors path filepath slices strings time pkglib errorsx
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+//spellchecker:words nosec
+
+// ErrArchiveLimit is returned when extracting an archive exceeds a limit set in [ExtractOptions].
+var ErrArchiveLimit = errors.New("archive exceeds extraction limit")
+
+// maxLinkTarget is the maximum length of a symbolic link target stored in a zip archive.
+const maxLinkTarget = 4096
+
+// ExtractOptions determine the behavior of [ExtractArchive], [ExtractTar] and [ExtractZip].
+type ExtractOptions struct {
+	// Format is the format of the archive passed to [ExtractArchive].
+	// If it is [FormatAuto], the format is determined using [ArchiveFormatOf].
+	Format ArchiveFormat
+
+	// MaxBytes is the maximum total size of the content of extracted files.
+	// MaxEntries is the maximum number of entries in the archive.
+	// Exceeding either limit aborts extraction with an error wrapping [ErrArchiveLimit].
+	// A limit of zero means no limit.
+	//
+	// Limits are enforced based on the actual extracted data, not sizes claimed by the archive.
+	MaxBytes   int64
+	MaxEntries int
+
+	// OnEntry, when not nil, is called for each entry once it has been extracted.
+	OnEntry func(entry ArchiveEntry)
+}
+
+// ExtractArchive extracts the archive at path into the directory dst.
+// The archive is extracted using [ExtractTar] or [ExtractZip], depending on the format.
+func ExtractArchive(dst, path string, opts ExtractOptions) (e error) {
+	format := opts.Format
+	if format == FormatAuto {
+		var err error
+		format, err = ArchiveFormatOf(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(path) // #nosec G304 -- path is an explicit parameter
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer errorsx.Close(file, &e, "archive")
+
+	switch format {
+	case FormatTar, FormatTarGz:
+		return ExtractTar(dst, file, opts)
+	case FormatZip:
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat archive: %w", err)
+		}
+		return ExtractZip(dst, file, info.Size(), opts)
+	case FormatAuto:
+		fallthrough
+	default:
+		return fmt.Errorf("%s: %w", format, ErrUnknownArchiveFormat)
+	}
+}
+
+// ExtractTar extracts the tar archive read from r into the directory dst.
+// Gzip-compressed archives are detected and decompressed automatically.
+//
+// Entries are extracted with their exact modes, regardless of the umask.
+// Modification times of files and directories are preserved.
+// Regular files, directories, symbolic links and hard links are extracted; other entries are skipped.
+// Existing files are overwritten, existing directories are merged.
+//
+// Entries must not escape dst: absolute names or names containing ".." components leaving dst
+// cause an error wrapping [fsx.ErrEscapesRoot].
+// Symbolic links inside dst are resolved using [fsx.SecureJoin], so no files are written outside of dst.
+func ExtractTar(dst string, r io.Reader, opts ExtractOptions) (e error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer errorsx.Close(zr, &e, "gzip reader")
+		r = zr
+	} else {
+		r = br
+	}
+
+	x, err := newExtractor(dst, opts)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		entry := extractEntry{
+			name:       header.Name,
+			mode:       header.FileInfo().Mode(),
+			size:       header.Size,
+			modTime:    header.ModTime,
+			accessTime: header.AccessTime,
+		}
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			entry.link = header.Linkname
+		case tar.TypeLink:
+			entry.hardlink = header.Linkname
+		}
+
+		if err := x.extract(entry, tr); err != nil {
+			return err
+		}
+	}
+
+	return x.finish()
+}
+
+// ExtractZip extracts the zip archive of the given size read from r into the directory dst.
+// Entries are extracted as described by [ExtractTar].
+func ExtractZip(dst string, r io.ReaderAt, size int64, opts ExtractOptions) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	x, err := newExtractor(dst, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range zr.File {
+		if err := x.extractZip(file); err != nil {
+			return err
+		}
+	}
+
+	return x.finish()
+}
+
+// extractEntry is an entry to be extracted, independent of the archive format.
+type extractEntry struct {
+	name       string      // slash-separated name in the archive
+	mode       fs.FileMode // mode, including type bits
+	size       int64       // claimed size of the content
+	link       string      // target of a symbolic link
+	hardlink   string      // name of the hard link target in the archive
+	modTime    time.Time   // modification time
+	accessTime time.Time   // access time, may be zero
+}
+
+// extractor extracts entries into a destination directory.
+type extractor struct {
+	dst  string
+	opts ExtractOptions
+
+	entries int   // number of entries extracted
+	bytes   int64 // number of content bytes extracted
+
+	// directories whose mode and times still need to be set.
+	// they can only be set once their content has been extracted.
+	dirs []extractedDir
+}
+
+type extractedDir struct {
+	path  string
+	entry extractEntry
+}
+
+func newExtractor(dst string, opts ExtractOptions) (*extractor, error) {
+	if err := MkdirAll(dst, DefaultDirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+	return &extractor{dst: dst, opts: opts}, nil
+}
+
+// extractZip extracts a single file from a zip archive.
+func (x *extractor) extractZip(file *zip.File) (e error) {
+	entry := extractEntry{
+		name:    file.Name,
+		mode:    file.Mode(),
+		size:    int64(file.UncompressedSize64), // #nosec G115 -- overflows are caught by the limits on actual data
+		modTime: file.Modified,
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", file.Name, err)
+	}
+	defer errorsx.Close(content, &e, "archive entry")
+
+	if entry.mode&fs.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(content, maxLinkTarget))
+		if err != nil {
+			return fmt.Errorf("failed to read link target of %q: %w", file.Name, err)
+		}
+		entry.link = string(target)
+	}
+
+	return x.extract(entry, content)
+}
+
+// extract extracts a single entry with the given content.
+func (x *extractor) extract(entry extractEntry, content io.Reader) error {
+	x.entries++
+	if x.opts.MaxEntries > 0 && x.entries > x.opts.MaxEntries {
+		return fmt.Errorf("more than %d entries: %w", x.opts.MaxEntries, ErrArchiveLimit)
+	}
+
+	dst, err := x.path(entry.name)
+	if err != nil || dst == "" {
+		return err
+	}
+
+	isDir := entry.mode.IsDir()
+	isRegular := entry.mode.IsRegular() || entry.hardlink != ""
+	if !isDir && !isRegular && entry.link == "" {
+		return nil
+	}
+
+	// remove an existing entry that is in the way.
+	// in particular, never write through an existing symbolic link.
+	existing, err := os.Lstat(dst)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		existing = nil
+	case err != nil:
+		return fmt.Errorf("failed to stat %q: %w", entry.name, err)
+	}
+	if existing != nil && (!isDir || !existing.IsDir()) {
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("failed to remove existing %q: %w", entry.name, err)
+		}
+		existing = nil
+
+		// a directory that has been replaced must no longer be updated by finish.
+		x.dirs = slices.DeleteFunc(x.dirs, func(dir extractedDir) bool { return dir.path == dst })
+	}
+
+	var size int64
+	switch {
+	case isDir:
+		// keep the directory writable until all of its content is extracted
+		if existing == nil {
+			if err := Mkdir(dst, entry.mode.Perm()|0o700); err != nil {
+				return fmt.Errorf("failed to extract %q: %w", entry.name, err)
+			}
+		}
+		x.dirs = append(x.dirs, extractedDir{path: dst, entry: entry})
+	case entry.link != "":
+		if err := os.Symlink(entry.link, dst); err != nil {
+			return fmt.Errorf("failed to extract %q: %w", entry.name, err)
+		}
+	case entry.hardlink != "":
+		target, err := x.path(entry.hardlink)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(target, dst); err != nil {
+			return fmt.Errorf("failed to extract %q: %w", entry.name, err)
+		}
+	default:
+		size, err = x.extractFile(dst, entry, content)
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", entry.name, err)
+		}
+	}
+
+	if x.opts.OnEntry != nil {
+		x.opts.OnEntry(ArchiveEntry{Name: entry.name, Path: dst, Mode: entry.mode, Size: size})
+	}
+	return nil
+}
+
+// extractFile extracts a regular file to dst and returns the number of bytes written.
+func (x *extractor) extractFile(dst string, entry extractEntry, content io.Reader) (n int64, e error) {
+	remaining := int64(-1)
+	if x.opts.MaxBytes > 0 {
+		remaining = x.opts.MaxBytes - x.bytes
+		if entry.size > remaining {
+			return 0, fmt.Errorf("more than %d bytes: %w", x.opts.MaxBytes, ErrArchiveLimit)
+		}
+		// read one more byte than allowed to detect exceeding the limit
+		content = io.LimitReader(content, remaining+1)
+	}
+
+	file, err := Create(dst, entry.mode.Perm()|entry.mode&(fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = io.Copy(file, content)
+	x.bytes += n
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close file: %w", closeErr)
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+	if remaining >= 0 && n > remaining {
+		return n, fmt.Errorf("more than %d bytes: %w", x.opts.MaxBytes, ErrArchiveLimit)
+	}
+
+	if err := x.chtimes(dst, entry); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// path returns the path on disk to extract the entry with the given name to.
+// If the entry refers to dst itself, returns the empty string.
+func (x *extractor) path(name string) (string, error) {
+	clean := path.Clean(name)
+	if !filepath.IsLocal(filepath.FromSlash(clean)) {
+		if clean == "." {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to extract %q: %w", name, fsx.ErrEscapesRoot)
+	}
+
+	// resolve the parent, so that we never write through a symbolic link leaving dst.
+	parent, err := fsx.SecureJoin(x.dst, path.Dir(clean))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %w", name, err)
+	}
+	if err := MkdirAll(parent, DefaultDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create parent of %q: %w", name, err)
+	}
+	return filepath.Join(parent, path.Base(clean)), nil
+}
+
+// chtimes sets the times of path to those of entry.
+func (x *extractor) chtimes(path string, entry extractEntry) error {
+	if entry.modTime.IsZero() {
+		return nil
+	}
+	atime := entry.accessTime
+	if atime.IsZero() {
+		atime = entry.modTime
+	}
+	if err := os.Chtimes(path, atime, entry.modTime); err != nil {
+		return fmt.Errorf("failed to set times: %w", err)
+	}
+	return nil
+}
+
+// finish sets the modes and times of extracted directories.
+// Children are handled before their parents, so that setting times of a child does not change those of the parent.
+//
+// Paths that are no longer directories are skipped, so that finish never follows a symbolic link out of dst.
+func (x *extractor) finish() error {
+	for _, dir := range slices.Backward(x.dirs) {
+		info, err := os.Lstat(dir.path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %q: %w", dir.entry.name, err)
+		}
+		if !info.IsDir() {
+			continue
+		}
+
+		if err := os.Chmod(dir.path, dir.entry.mode); err != nil {
+			return fmt.Errorf("failed to set mode of %q: %w", dir.entry.name, err)
+		}
+		if err := x.chtimes(dir.path, dir.entry); err != nil {
+			return fmt.Errorf("failed to set times of %q: %w", dir.entry.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words errors testing fstest time pkglib umaskfree
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx"
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+)
+
+func TestCopyDirectoryFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fsx.NewMemFS()
+	if err := umaskfree.MkdirAllFS(fsys, "src/sub", 0o705); err != nil {
+		t.Fatalf("MkdirAllFS() = %v", err)
+	}
+	if err := umaskfree.TouchFS(fsys, "src/sub/empty", 0o600); err != nil {
+		t.Fatalf("TouchFS() = %v", err)
+	}
+	file, err := fsys.Create("src/file", 0o751)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Symlink("sub/empty", "src/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	var copied int
+	if err := umaskfree.CopyDirectoryFS(fsys, "dst", "src", func(dst, src string) { copied++ }); err != nil {
+		t.Fatalf("CopyDirectoryFS() = %v", err)
+	}
+	if copied != 5 {
+		t.Errorf("CopyDirectoryFS() copied %d entries, want 5", copied)
+	}
+
+	for name, want := range map[string]fs.FileMode{
+		"dst":           fs.ModeDir | 0o705,
+		"dst/sub":       fs.ModeDir | 0o705,
+		"dst/sub/empty": 0o600,
+		"dst/file":      0o751,
+		"dst/link":      fs.ModeSymlink | fs.ModePerm,
+	} {
+		info, err := fsys.Lstat(name)
+		if err != nil {
+			t.Errorf("Lstat(%q) = %v", name, err)
+			continue
+		}
+		if info.Mode() != want {
+			t.Errorf("%q has mode %v, want %v", name, info.Mode(), want)
+		}
+	}
+	if data, err := fs.ReadFile(fsys, "dst/file"); err != nil || string(data) != "content" {
+		t.Errorf("ReadFile() = (%q, %v), want %q", data, err, "content")
+	}
+
+	// copying onto itself or into a file fails
+	if err := umaskfree.CopyDirectoryFS(fsys, "src/../src", "src", nil); !errors.Is(err, umaskfree.ErrCopySameFile) {
+		t.Errorf("CopyDirectoryFS(same) = %v, want %v", err, umaskfree.ErrCopySameFile)
+	}
+	if err := umaskfree.CopyDirectoryFS(fsys, "src/file", "src/sub", nil); !errors.Is(err, umaskfree.ErrDstFile) {
+		t.Errorf("CopyDirectoryFS(file) = %v, want %v", err, umaskfree.ErrDstFile)
+	}
+
+	// files in the way of directories are kept
+	if err := umaskfree.MkdirAllFS(fsys, "blocked", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := umaskfree.TouchFS(fsys, "blocked/sub", 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := umaskfree.CopyDirectoryFS(fsys, "blocked", "src", nil); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("CopyDirectoryFS(blocked) = %v, want %v", err, fs.ErrExist)
+	}
+	if info, err := fsys.Lstat("blocked/sub"); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("Lstat(blocked/sub) = (%v, %v), want a regular file", info, err)
+	}
+}
+
+func TestCopyDirectoryWithFS(t *testing.T) {
+	t.Parallel()
+
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fsys := fsx.NewMemFS()
+	for _, dir := range []string{"src", "dst"} {
+		if err := umaskfree.MkdirAllFS(fsys, dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, perm := range map[string]fs.FileMode{"src/keep": 0o644, "src/new": 0o600, "src/skip.tmp": 0o644, "dst/keep": 0o644} {
+		if err := umaskfree.TouchFS(fsys, name, perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.Chtimes(name, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actions := make(map[string]umaskfree.CopyAction)
+	err := umaskfree.CopyDirectoryWithFS(fsys, "dst", "src", umaskfree.CopyOptions{
+		Exclude:       []string{"*.tmp"},
+		Conflict:      umaskfree.ConflictNewer,
+		PreserveTimes: true,
+		OnEntry:       func(entry umaskfree.CopyEntry) { actions[entry.Dst] = entry.Action },
+	})
+	if err != nil {
+		t.Fatalf("CopyDirectoryWithFS() = %v", err)
+	}
+
+	for name, want := range map[string]umaskfree.CopyAction{
+		"dst":          umaskfree.CopyMerged,
+		"dst/keep":     umaskfree.CopySkipped,
+		"dst/new":      umaskfree.CopyCreated,
+		"dst/skip.tmp": umaskfree.CopyExcluded,
+	} {
+		if got, ok := actions[name]; !ok || got != want {
+			t.Errorf("action for %q = %v, want %v", name, got, want)
+		}
+	}
+	if info, err := fsys.Stat("dst/new"); err != nil || !info.ModTime().Equal(past) || info.Mode() != 0o600 {
+		t.Errorf("Stat(dst/new) = (%v, %v), want mode %v and time %v", info, err, fs.FileMode(0o600), past)
+	}
+	if _, err := fsys.Lstat("dst/skip.tmp"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Lstat(dst/skip.tmp) = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	// files can not be cloned within the file system
+	err = umaskfree.CopyDirectoryWithFS(fsys, "clone", "src", umaskfree.CopyOptions{Clone: umaskfree.CloneAlways})
+	if !errors.Is(err, umaskfree.ErrCloneUnsupported) {
+		t.Errorf("CopyDirectoryWithFS(CloneAlways) = %v, want %v", err, umaskfree.ErrCloneUnsupported)
+	}
+}
+
+func TestTouchFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fsx.NewMemFS()
+	if err := umaskfree.TouchFS(fsys, "file", 0); err != nil {
+		t.Fatalf("TouchFS() = %v", err)
+	}
+	if info, err := fsys.Stat("file"); err != nil || info.Mode() != umaskfree.DefaultFilePerm {
+		t.Errorf("Stat() = (%v, %v), want mode %v", info, err, umaskfree.DefaultFilePerm)
+	}
+
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := fsys.Chtimes("file", past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := umaskfree.TouchFS(fsys, "file", 0); err != nil {
+		t.Fatalf("TouchFS() = %v", err)
+	}
+	if info, err := fsys.Stat("file"); err != nil || !info.ModTime().After(past) {
+		t.Errorf("Stat() = (%v, %v), want updated modification time", info, err)
+	}
+
+	// read-only file systems can not be touched
+	ro := fsx.ReadOnly(fstest.MapFS{})
+	if err := umaskfree.TouchFS(ro, "file", 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("TouchFS(read-only) = %v, want %v", err, fs.ErrPermission)
+	}
+}
@@ -0,0 +1,332 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words context errors path filepath slices pkglib errorsx perf
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/fsx"
+	"go.tkw01536.de/pkglib/perf"
+)
+
+//spellchecker:words nolint wrapcheck
+
+// MirrorOptions determine the behavior of [Mirror].
+type MirrorOptions struct {
+	// Exclude holds glob patterns of entries to ignore, using the syntax of [CopyOptions.Exclude].
+	// Excluded entries are neither copied nor deleted.
+	Exclude []string
+
+	// Delete indicates that entries in the destination that do not exist in the source should be deleted.
+	Delete bool
+
+	// Compare determines how regular files are compared to determine if they need to be copied.
+	Compare fsx.CompareMode
+
+	// PreserveOwner indicates that owner and group of each copied entry should be preserved.
+	// Failures due to insufficient permissions are silently ignored.
+	PreserveOwner bool
+
+	// Clone determines how the content of regular files is copied, see [CopyOptions.Clone].
+	Clone CloneMode
+
+	// DryRun indicates that no changes should be made to the destination.
+	// The returned summary holds the operations that would have been performed.
+	DryRun bool
+
+	// OnChange, when not nil, is called for each change before it is applied.
+	// Changes are computed by [fsx.Diff] with the destination as the first and the source as the second tree.
+	OnChange func(change fsx.Change)
+}
+
+// MirrorSummary summarizes the operations performed by [Mirror].
+type MirrorSummary struct {
+	Created int   // number of entries created in the destination
+	Updated int   // number of entries whose content, link target, mode or type was updated
+	Deleted int   // number of entries deleted from the destination
+	Bytes   int64 // number of bytes of regular files copied
+}
+
+func (summary MirrorSummary) String() string {
+	return fmt.Sprintf(
+		"%d created, %d updated, %d deleted, %s copied",
+		summary.Created, summary.Updated, summary.Deleted, perf.HumanBytes(summary.Bytes),
+	)
+}
+
+// Mirror makes the directory dst identical to the directory src, and returns a summary of the operations performed.
+//
+// Only entries that differ are copied, as determined by [fsx.Diff] using opts.Compare.
+// Modes are kept exactly, regardless of the umask, and modification times of files are preserved,
+// so that unchanged files are detected on subsequent runs.
+// Entries in dst that do not exist in src are only deleted if opts.Delete is set.
+//
+// If an error occurs, the summary holds the operations performed so far.
+func Mirror(dst, src string, opts MirrorOptions) (MirrorSummary, error) {
+	var summary MirrorSummary
+	if err := checkDirectoryCopy(fsx.OS, dst, src); err != nil {
+		return summary, err
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return summary, fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	// create the destination if needed
+	exists, err := fsx.Exists(dst)
+	if err != nil {
+		return summary, fmt.Errorf("failed to check destination: %w", err)
+	}
+	if !exists {
+		summary.Created++
+		if opts.DryRun {
+			return opts.dryCreate(summary, src)
+		}
+		if err := Mkdir(dst, srcInfo.Mode()); err != nil {
+			return summary, err
+		}
+	}
+
+	// compute all changes up front, as the destination is modified while applying them
+	var changes []fsx.Change
+	for change, err := range fsx.DiffDirectory(dst, src, fsx.DiffOptions{Compare: opts.Compare, Skip: opts.skip}) {
+		if err != nil {
+			return summary, err //nolint:wrapcheck // errors are already wrapped
+		}
+		changes = append(changes, change)
+	}
+
+	m := mirror{opts: opts, dst: dst, src: src, summary: &summary}
+	for _, change := range changes {
+		if err := m.apply(change); err != nil {
+			return summary, err
+		}
+	}
+
+	// set directory times, innermost first
+	if !opts.DryRun {
+		for _, dir := range slices.Backward(m.dirs) {
+			if err := os.Chtimes(dir.Dst, accessTime(dir.Info), dir.Info.ModTime()); err != nil {
+				return summary, fmt.Errorf("failed to preserve times: %w", err)
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// skip checks if the slash-separated relative path is excluded.
+func (opts MirrorOptions) skip(relPath string, isDir bool) bool {
+	return slices.ContainsFunc(opts.Exclude, func(pattern string) bool { return matchPattern(pattern, relPath) })
+}
+
+// dryCreate adds the operations needed to copy src to a destination that does not exist to summary.
+func (opts MirrorOptions) dryCreate(summary MirrorSummary, src string) (MirrorSummary, error) {
+	err := filepath.WalkDir(src, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, current)
+		if err != nil {
+			return fmt.Errorf("failed to determine relative path: %w", err)
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		if opts.skip(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat source: %w", err)
+		}
+		if opts.OnChange != nil {
+			opts.OnChange(fsx.Change{Path: relPath, Kind: fsx.ChangeAdded, B: info})
+		}
+
+		summary.Created++
+		if info.Mode().IsRegular() {
+			summary.Bytes += info.Size()
+		}
+		return nil
+	})
+	return summary, err //nolint:wrapcheck // errors are already wrapped
+}
+
+// mirror holds the state of [Mirror] while applying changes.
+type mirror struct {
+	opts     MirrorOptions
+	dst, src string
+	summary  *MirrorSummary
+
+	removed []string    // slash-separated paths of directories that were removed, along with their content
+	dirs    []CopyEntry // directories whose times still need to be set
+}
+
+// apply applies a single change to the destination.
+func (m *mirror) apply(change fsx.Change) error {
+	// the entry was already removed along with its parent
+	if m.isRemoved(change.Path) {
+		if change.Kind == fsx.ChangeRemoved {
+			m.summary.Deleted++
+		}
+		return nil
+	}
+
+	// extraneous entries are kept unless requested otherwise
+	if change.Kind == fsx.ChangeRemoved && !m.opts.Delete {
+		return nil
+	}
+
+	if m.opts.OnChange != nil {
+		m.opts.OnChange(change)
+	}
+
+	entry := CopyEntry{
+		Src:  filepath.Join(m.src, filepath.FromSlash(change.Path)),
+		Dst:  filepath.Join(m.dst, filepath.FromSlash(change.Path)),
+		Info: change.B,
+	}
+
+	switch {
+	case change.Kind == fsx.ChangeRemoved:
+		m.summary.Deleted++
+		return m.remove(change.Path, entry.Dst, change.A)
+
+	case change.Kind == fsx.ChangeAdded:
+		m.summary.Created++
+		return m.copy(entry)
+
+	case change.Diff&fsx.DiffType != 0:
+		// the type changed, so the entry is replaced
+		m.summary.Updated++
+		if err := m.remove(change.Path, entry.Dst, change.A); err != nil {
+			return err
+		}
+		return m.copy(entry)
+
+	case change.Diff&(fsx.DiffContent|fsx.DiffTarget) != 0:
+		m.summary.Updated++
+		return m.copy(entry)
+
+	default:
+		// only the mode changed
+		m.summary.Updated++
+		if m.opts.DryRun {
+			return nil
+		}
+		if err := os.Chmod(entry.Dst, entry.Info.Mode()); err != nil {
+			return fmt.Errorf("failed to chmod: %w", err)
+		}
+		return nil
+	}
+}
+
+// isRemoved checks if the slash-separated relative path is inside a removed directory.
+func (m *mirror) isRemoved(relPath string) bool {
+	for relPath != "." {
+		relPath = path.Dir(relPath)
+		if slices.Contains(m.removed, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes the entry at dst, described by info.
+func (m *mirror) remove(relPath, dst string, info fs.FileInfo) error {
+	if info.IsDir() {
+		m.removed = append(m.removed, relPath)
+	}
+	if m.opts.DryRun {
+		return nil
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("failed to remove %q: %w", dst, err)
+	}
+	return nil
+}
+
+// copy copies a single entry, which does not exist in the destination or needs to be overwritten.
+func (m *mirror) copy(entry CopyEntry) error {
+	if entry.Info.Mode().IsRegular() {
+		m.summary.Bytes += entry.Info.Size()
+	}
+	if m.opts.DryRun {
+		return nil
+	}
+
+	var err error
+	switch {
+	case entry.Info.IsDir():
+		err = Mkdir(entry.Dst, entry.Info.Mode())
+	case entry.Info.Mode()&fs.ModeSymlink != 0:
+		err = CopyLink(entry.Dst, entry.Src)
+	default:
+		err = m.copyFile(entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := CopyOptions{PreserveTimes: true, PreserveOwner: m.opts.PreserveOwner}
+	return opts.preserve(fsx.OS, entry, &m.dirs)
+}
+
+// copyFile copies a regular file, removing a destination that is not a regular file first.
+//
+// The file is written to a temporary file and renamed over the destination, see [AtomicFile].
+// An existing destination is thus replaced instead of being written to.
+// This leaves other hard links to it unchanged, allows replacing read-only files,
+// and leaves the destination intact if the copy fails.
+func (m *mirror) copyFile(entry CopyEntry) (e error) {
+	info, err := os.Lstat(entry.Dst)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to stat destination: %w", err)
+	case !info.Mode().IsRegular():
+		if err := os.Remove(entry.Dst); err != nil {
+			return fmt.Errorf("failed to remove destination: %w", err)
+		}
+	}
+
+	srcFile, err := os.Open(entry.Src) // #nosec G304 -- src is part of the mirrored tree
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer errorsx.Close(srcFile, &e, "source file")
+
+	srcStat, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	af, err := CreateAtomic(entry.Dst, srcStat.Mode(), AtomicOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		e = errorsx.Combine(e, af.Abort())
+	}()
+
+	if err := copyContent(context.Background(), entry.Dst, af.file, srcFile, srcStat.Size(), m.opts.Clone, nil); err != nil {
+		return err
+	}
+	return af.Close()
+}
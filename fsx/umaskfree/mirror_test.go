@@ -0,0 +1,137 @@
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words errors path filepath testing pkglib umaskfree
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.tkw01536.de/pkglib/fsx"
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+)
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{
+		"same":          "same",
+		"changed":       "new content",
+		"sub/added":     "added",
+		"typed/file":    "file",
+		"keep.log":      "source log",
+		"exec":          "#!/bin/sh",
+		"deep/er/entry": "entry",
+	})
+	if err := os.Chmod(filepath.Join(src, "exec"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// first mirror into a new directory
+	dst := filepath.Join(t.TempDir(), "dst")
+	summary, err := umaskfree.Mirror(dst, src, umaskfree.MirrorOptions{})
+	if err != nil {
+		t.Fatalf("Mirror() = %v", err)
+	}
+	if want := (umaskfree.MirrorSummary{Created: 12, Bytes: 48}); summary != want {
+		t.Errorf("first Mirror() returned %v, want %v", summary, want)
+	}
+
+	// mirroring again should do nothing
+	summary, err = umaskfree.Mirror(dst, src, umaskfree.MirrorOptions{})
+	if err != nil {
+		t.Fatalf("Mirror() = %v", err)
+	}
+	if (summary != umaskfree.MirrorSummary{}) {
+		t.Errorf("second Mirror() returned %v, want no operations", summary)
+	}
+
+	// make some changes to the destination
+	for _, name := range []string{"typed", "sub/added"} {
+		if err := os.RemoveAll(filepath.Join(dst, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{"changed": "old", "typed": "not a directory", "extra": "extra", "keep.log": "dst log"} {
+		if err := umaskfree.WriteFile(filepath.Join(dst, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dst, "exec"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err = umaskfree.Mirror(dst, src, umaskfree.MirrorOptions{Delete: true, Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("Mirror() = %v", err)
+	}
+	// created: sub/added, typed/file; updated: changed, exec, typed; deleted: extra
+	if want := (umaskfree.MirrorSummary{Created: 2, Updated: 3, Deleted: 1, Bytes: 20}); summary != want {
+		t.Errorf("third Mirror() returned %v, want %v", summary, want)
+	}
+
+	assertFile(t, filepath.Join(dst, "changed"), "new content", 0o644)
+	assertFile(t, filepath.Join(dst, "typed", "file"), "file", 0o644)
+	assertFile(t, filepath.Join(dst, "exec"), "#!/bin/sh", 0o755)
+	assertFile(t, filepath.Join(dst, "keep.log"), "dst log", 0o644)
+	if _, err := os.Lstat(filepath.Join(dst, "extra")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("extraneous file was not deleted")
+	}
+
+	// only the excluded file should remain different
+	for change, err := range fsx.DiffDirectory(dst, src, fsx.DiffOptions{Compare: fsx.CompareHash}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if change.Path != "." && change.Path != "keep.log" {
+			t.Errorf("unexpected difference after mirroring: %v", change)
+		}
+	}
+}
+
+func TestMirror_dryRun(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{"file": "content", "sub/file": "other"})
+	dst := makeTree(t, map[string]string{"extra/file": "extra"})
+
+	summary, err := umaskfree.Mirror(dst, src, umaskfree.MirrorOptions{DryRun: true, Delete: true})
+	if err != nil {
+		t.Fatalf("Mirror() = %v", err)
+	}
+	if want := (umaskfree.MirrorSummary{Created: 3, Deleted: 2, Bytes: 12}); summary != want {
+		t.Errorf("Mirror() returned %v, want %v", summary, want)
+	}
+
+	if _, err := os.Lstat(filepath.Join(dst, "extra", "file")); err != nil {
+		t.Errorf("dry run deleted file: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "file")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("dry run created file")
+	}
+}
+
+func TestMirror_replace(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{"file": "new content"})
+	dst := makeTree(t, map[string]string{"file": "old"})
+
+	// make the destination read-only, and hard link it elsewhere
+	if err := os.Chmod(filepath.Join(dst, "file"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Link(filepath.Join(dst, "file"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := umaskfree.Mirror(dst, src, umaskfree.MirrorOptions{}); err != nil {
+		t.Fatalf("Mirror() = %v", err)
+	}
+
+	assertFile(t, filepath.Join(dst, "file"), "new content", 0o644)
+	assertFile(t, link, "old", 0o444)
+}
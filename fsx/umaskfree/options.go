@@ -0,0 +1,411 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words errors path filepath slices strings pkglib
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.tkw01536.de/pkglib/fsx"
+)
+
+//spellchecker:words nolint wrapcheck
+
+// ConflictPolicy determines what happens when a file to be copied already exists in the destination.
+type ConflictPolicy uint8
+
+const (
+	// ConflictOverwrite overwrites existing files.
+	ConflictOverwrite ConflictPolicy = iota
+
+	// ConflictSkip keeps existing files.
+	ConflictSkip
+
+	// ConflictError returns an error wrapping [ErrCopyConflict].
+	ConflictError
+
+	// ConflictNewer overwrites existing files only if the source file has a newer modification time.
+	ConflictNewer
+)
+
+// ErrCopyConflict is returned when a file to be copied already exists and the policy is [ConflictError].
+var ErrCopyConflict = errors.New("destination already exists")
+
+// CopyAction describes the action taken for a single entry when copying a directory.
+type CopyAction uint8
+
+const (
+	CopyCreated     CopyAction = iota // the destination did not exist and was created
+	CopyOverwritten                   // the destination existed and was overwritten
+	CopyMerged                        // the destination was an existing directory and was kept
+	CopySkipped                       // the destination existed and was kept because of the conflict policy
+	CopyExcluded                      // the entry was excluded by a filter
+)
+
+func (action CopyAction) String() string {
+	switch action {
+	case CopyCreated:
+		return "created"
+	case CopyOverwritten:
+		return "overwritten"
+	case CopyMerged:
+		return "merged"
+	case CopySkipped:
+		return "skipped"
+	case CopyExcluded:
+		return "excluded"
+	default:
+		return "unknown"
+	}
+}
+
+// CopyEntry describes a single entry copied by [CopyDirectoryWith].
+type CopyEntry struct {
+	Src    string      // path of the entry in the source
+	Dst    string      // path of the entry in the destination
+	Info   fs.FileInfo // information about the source entry, as returned by [os.Lstat]
+	Action CopyAction  // action taken
+}
+
+// CopyOptions determine the behavior of [CopyDirectoryWith].
+type CopyOptions struct {
+	// Include and Exclude hold glob patterns determining which entries are copied.
+	// Patterns use the syntax of [path.Match] and are matched against slash-separated paths relative to the source.
+	// A pattern that does not contain a slash is matched against the base name of each entry instead.
+	//
+	// If Include is non-empty, only files matching at least one Include pattern are copied.
+	// Directories are always traversed, regardless of Include.
+	// Entries matching any Exclude pattern are not copied; excluded directories are not traversed.
+	Include []string
+	Exclude []string
+
+	// Conflict determines what happens when a file already exists in the destination.
+	// Existing directories are always merged.
+	Conflict ConflictPolicy
+
+	// PreserveTimes indicates that access and modification times of files and directories should be preserved.
+	// This includes existing directories that are merged into.
+	// Times of symbolic links are not preserved.
+	PreserveTimes bool
+
+	// PreserveOwner indicates that owner and group of each entry should be preserved.
+	// Failures due to insufficient permissions are silently ignored.
+	PreserveOwner bool
+
+	// Clone determines how the content of regular files is copied.
+	// By default, the content is copied in user space; see [CloneAuto] to clone files using copy-on-write.
+	Clone CloneMode
+
+	// Hardlinks indicates that files which are hard links to the same file in the source
+	// should also be hard links to the same file in the destination.
+	// By default, each hard link is copied as an independent file.
+	Hardlinks bool
+
+	// DryRun indicates that no changes should be made to the destination.
+	// OnEntry is still called with the actions that would have been taken.
+	DryRun bool
+
+	// OnEntry, when not nil, is called for each entry once its action has been taken.
+	OnEntry func(entry CopyEntry)
+}
+
+// CopyDirectoryWith copies the directory src to dst recursively, as configured by opts.
+// Modes of copied files and directories are kept exactly, regardless of the umask.
+//
+// When a directory already exists, additional files are not deleted.
+func CopyDirectoryWith(dst, src string, opts CopyOptions) error {
+	return CopyDirectoryWithFS(fsx.OS, dst, src, opts)
+}
+
+// CopyDirectoryWithFS is like [CopyDirectoryWith], but operates on fsys.
+//
+// Hard links and owners can only be preserved when fsys is [fsx.OS]; otherwise opts.Hardlinks and opts.PreserveOwner are ignored.
+// Files can only be cloned when fsys is [fsx.OS].
+func CopyDirectoryWithFS(fsys fsx.WritableFS, dst, src string, opts CopyOptions) error {
+	if err := checkDirectoryCopy(fsys, dst, src); err != nil {
+		return err
+	}
+
+	// directories whose times still need to be set.
+	// they can only be set once their content has been copied.
+	var dirs []CopyEntry
+
+	// destinations of files that are hard links in the source.
+	links := make(map[fileKey]string)
+
+	err := walkDir(fsys, src, func(current string, d fs.DirEntry, err error) error {
+		// someone previously returned an error
+		if err != nil {
+			return err
+		}
+
+		// determine the real target path
+		relPath, err := filepath.Rel(src, current)
+		if err != nil {
+			return fmt.Errorf("failed to determine relative path: %w", err)
+		}
+
+		// stat the entry, so that we can get mode, and info later!
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat source: %w", err)
+		}
+
+		entry := CopyEntry{Src: current, Dst: filepath.Join(dst, relPath), Info: info}
+
+		// check if the entry is excluded
+		if relPath != "." && opts.excluded(filepath.ToSlash(relPath), d.IsDir()) {
+			entry.Action = CopyExcluded
+			opts.report(entry)
+
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// link to a previously copied file if possible
+		key, linked := opts.fileKey(fsys, info)
+		target := links[key]
+
+		entry.Action, err = opts.copyEntry(context.Background(), fsys, entry.Dst, entry.Src, info, target, nil)
+		if err != nil {
+			return err
+		}
+		if linked && target == "" && (entry.Action == CopyCreated || entry.Action == CopyOverwritten) {
+			links[key] = entry.Dst
+		}
+
+		if !opts.DryRun && entry.Action != CopySkipped {
+			if err := opts.preserve(fsys, entry, &dirs); err != nil {
+				return err
+			}
+		}
+
+		opts.report(entry)
+		return nil
+	})
+	if err != nil {
+		return err //nolint:wrapcheck // errors are already wrapped
+	}
+
+	// set the times of directories, innermost first
+	for _, entry := range slices.Backward(dirs) {
+		if err := fsys.Chtimes(entry.Dst, accessTime(entry.Info), entry.Info.ModTime()); err != nil {
+			return fmt.Errorf("failed to preserve times: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// walkDir is like [filepath.WalkDir], but walks the tree rooted at root within fsys.
+// Like [filepath.WalkDir], it does not follow symbolic links, including root itself.
+func walkDir(fsys fsx.WritableFS, root string, fn fs.WalkDirFunc) error {
+	info, err := fsys.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkDirEntry recursively walks the entry d with the given path, see [walkDir].
+func walkDirEntry(fsys fsx.WritableFS, current string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(current, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, fs.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fsys.ReadDir(current)
+	if err != nil {
+		// report the error, and give fn the chance to skip the directory
+		err = fn(current, d, err)
+		if err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkDirEntry(fsys, filepath.Join(current, entry.Name()), entry, fn); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDirectoryCopy performs sanity checks before copying the directory src to dst within fsys.
+func checkDirectoryCopy(fsys fsx.WritableFS, dst, src string) error {
+	if fsx.SameFS(fsys, src, dst) {
+		return ErrCopySameFile
+	}
+
+	// check that the destination is not a regular file
+	isRegular, err := fsx.IsRegularFS(fsys, dst, true)
+	if err != nil {
+		return fmt.Errorf("failed to check destination file: %w", err)
+	}
+	if isRegular {
+		return ErrDstFile
+	}
+	return nil
+}
+
+// report calls OnEntry for entry, if it is set.
+func (opts CopyOptions) report(entry CopyEntry) {
+	if opts.OnEntry != nil {
+		opts.OnEntry(entry)
+	}
+}
+
+// excluded checks if the entry with the given slash-separated relative path should not be copied.
+func (opts CopyOptions) excluded(relPath string, isDir bool) bool {
+	if slices.ContainsFunc(opts.Exclude, func(pattern string) bool { return matchPattern(pattern, relPath) }) {
+		return true
+	}
+	if isDir || len(opts.Include) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(opts.Include, func(pattern string) bool { return matchPattern(pattern, relPath) })
+}
+
+// matchPattern checks if the given slash-separated relative path matches pattern.
+// An invalid pattern never matches.
+func matchPattern(pattern, relPath string) bool {
+	name := relPath
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(relPath)
+	}
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+// fileKey returns the key identifying the file described by info, if hard links should be preserved for it.
+// Hard links are only preserved within [fsx.OS].
+func (opts CopyOptions) fileKey(fsys fsx.WritableFS, info fs.FileInfo) (fileKey, bool) {
+	if !opts.Hardlinks || fsys != fsx.OS || !info.Mode().IsRegular() {
+		return fileKey{}, false
+	}
+	return hardlinkKey(info)
+}
+
+// copyEntry copies a single entry from src to dst within fsys and returns the action taken.
+// If target is not empty, a regular file is created as a hard link to target instead of being copied.
+// ctx and progress are passed to [copyFile] for regular files.
+func (opts CopyOptions) copyEntry(ctx context.Context, fsys fsx.WritableFS, dst, src string, info fs.FileInfo, target string, progress func(n int64)) (CopyAction, error) {
+	dstInfo, err := fsys.Lstat(dst)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("failed to stat destination: %w", err)
+	}
+
+	// directories are created, or merged if they already exist
+	if info.IsDir() {
+		if exists && dstInfo.IsDir() {
+			return CopyMerged, nil
+		}
+		if opts.DryRun {
+			return CopyCreated, nil
+		}
+		return CopyCreated, fsys.Mkdir(dst, info.Mode())
+	}
+
+	action := CopyCreated
+	if exists {
+		action, err = opts.resolve(dst, info, dstInfo)
+		if err != nil || action == CopySkipped {
+			return action, err
+		}
+
+		// remove anything that is not a regular file or directory (e.g. a link).
+		// so that we don't write through it.
+		// Also remove files that are about to be replaced by a hard link.
+		if !opts.DryRun && ((!dstInfo.Mode().IsRegular() && !dstInfo.IsDir()) || (target != "" && dstInfo.Mode().IsRegular())) {
+			if err := fsys.Remove(dst); err != nil {
+				return 0, fmt.Errorf("failed to remove destination: %w", err)
+			}
+		}
+	}
+	if opts.DryRun {
+		return action, nil
+	}
+
+	// if we have a symbolic link, copy the link!
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return action, CopyLinkFS(fsys, dst, src)
+	}
+
+	// if we have a previous copy of the file, link to it!
+	if target != "" {
+		if err := os.Link(target, dst); err != nil {
+			return 0, fmt.Errorf("failed to create hard link: %w", err)
+		}
+		return action, nil
+	}
+	return action, copyFile(ctx, fsys, dst, src, opts.Clone, progress)
+}
+
+// resolve applies the conflict policy to an existing destination.
+func (opts CopyOptions) resolve(dst string, info, dstInfo fs.FileInfo) (CopyAction, error) {
+	switch opts.Conflict {
+	case ConflictSkip:
+		return CopySkipped, nil
+	case ConflictError:
+		return 0, fmt.Errorf("%q: %w", dst, ErrCopyConflict)
+	case ConflictNewer:
+		if !info.ModTime().After(dstInfo.ModTime()) {
+			return CopySkipped, nil
+		}
+		return CopyOverwritten, nil
+	case ConflictOverwrite:
+		fallthrough
+	default:
+		return CopyOverwritten, nil
+	}
+}
+
+// preserve preserves metadata of an entry copied within fsys.
+// Directories are appended to dirs, as their times can only be set after their content has been copied.
+//
+// Of merged directories, only times are preserved.
+// Owners are only preserved within [fsx.OS].
+func (opts CopyOptions) preserve(fsys fsx.WritableFS, entry CopyEntry, dirs *[]CopyEntry) error {
+	if opts.PreserveOwner && fsys == fsx.OS && entry.Action != CopyMerged {
+		if err := lchownLike(entry.Dst, entry.Info); err != nil {
+			return err
+		}
+	}
+
+	if !opts.PreserveTimes || entry.Info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+
+	if entry.Info.IsDir() {
+		*dirs = append(*dirs, entry)
+		return nil
+	}
+
+	if err := fsys.Chtimes(entry.Dst, accessTime(entry.Info), entry.Info.ModTime()); err != nil {
+		return fmt.Errorf("failed to preserve times: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words errors path filepath slices testing time pkglib umaskfree
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+)
+
+// makeTree creates a directory tree with the given files in a new temporary directory.
+// Files are created with mode 0644, directories with mode 0755.
+func makeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := umaskfree.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := umaskfree.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// copyActions copies src to dst and records the actions by relative destination path.
+func copyActions(t *testing.T, dst, src string, opts umaskfree.CopyOptions) (map[string]umaskfree.CopyAction, error) {
+	t.Helper()
+
+	actions := make(map[string]umaskfree.CopyAction)
+	opts.OnEntry = func(entry umaskfree.CopyEntry) {
+		rel, err := filepath.Rel(dst, entry.Dst)
+		if err != nil {
+			t.Errorf("OnEntry: %v", err)
+			return
+		}
+		actions[filepath.ToSlash(rel)] = entry.Action
+
+		// check that src and dst refer to the same relative paths
+		srcRel, err := filepath.Rel(src, entry.Src)
+		if err != nil || srcRel != rel {
+			t.Errorf("OnEntry: got src %q and dst %q", entry.Src, entry.Dst)
+		}
+	}
+	err := umaskfree.CopyDirectoryWith(dst, src, opts)
+	return actions, err
+}
+
+func TestCopyDirectoryWith_filters(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{
+		"a.txt":         "a",
+		"b.log":         "b",
+		"sub/c.txt":     "c",
+		"sub/d.log":     "d",
+		"skip/e.txt":    "e",
+		"sub/skip/f.go": "f",
+	})
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	actions, err := copyActions(t, dst, src, umaskfree.CopyOptions{
+		Include: []string{"*.txt"},
+		Exclude: []string{"skip"},
+	})
+	if err != nil {
+		t.Fatalf("CopyDirectoryWith() = %v", err)
+	}
+
+	want := map[string]umaskfree.CopyAction{
+		".":         umaskfree.CopyCreated,
+		"a.txt":     umaskfree.CopyCreated,
+		"b.log":     umaskfree.CopyExcluded,
+		"sub":       umaskfree.CopyCreated,
+		"sub/c.txt": umaskfree.CopyCreated,
+		"sub/d.log": umaskfree.CopyExcluded,
+		"skip":      umaskfree.CopyExcluded,
+		"sub/skip":  umaskfree.CopyExcluded,
+	}
+	assertActions(t, actions, want)
+
+	assertFile(t, filepath.Join(dst, "sub", "c.txt"), "c", 0o644)
+	if _, err := os.Lstat(filepath.Join(dst, "b.log")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("excluded file was copied")
+	}
+}
+
+func TestCopyDirectoryWith_conflict(t *testing.T) {
+	t.Parallel()
+
+	old := time.Now().Add(-time.Hour)
+	recent := time.Now()
+
+	tests := []struct {
+		name        string
+		policy      umaskfree.ConflictPolicy
+		srcTime     time.Time
+		wantAction  umaskfree.CopyAction
+		wantContent string
+		wantErr     error
+	}{
+		{"overwrite", umaskfree.ConflictOverwrite, old, umaskfree.CopyOverwritten, "src", nil},
+		{"skip", umaskfree.ConflictSkip, recent, umaskfree.CopySkipped, "dst", nil},
+		{"error", umaskfree.ConflictError, recent, 0, "dst", umaskfree.ErrCopyConflict},
+		{"newer with newer source", umaskfree.ConflictNewer, recent, umaskfree.CopyOverwritten, "src", nil},
+		{"newer with older source", umaskfree.ConflictNewer, old, umaskfree.CopySkipped, "dst", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := makeTree(t, map[string]string{"file": "src"})
+			dst := makeTree(t, map[string]string{"file": "dst"})
+
+			mid := old.Add(time.Minute)
+			if err := os.Chtimes(filepath.Join(dst, "file"), mid, mid); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(filepath.Join(src, "file"), tt.srcTime, tt.srcTime); err != nil {
+				t.Fatal(err)
+			}
+
+			actions, err := copyActions(t, dst, src, umaskfree.CopyOptions{Conflict: tt.policy})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CopyDirectoryWith() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && actions["file"] != tt.wantAction {
+				t.Errorf("got action %v, want %v", actions["file"], tt.wantAction)
+			}
+			assertFile(t, filepath.Join(dst, "file"), tt.wantContent, 0o644)
+		})
+	}
+}
+
+func TestCopyDirectoryWith_dryRun(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{"file": "src", "sub/other": "other"})
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	actions, err := copyActions(t, dst, src, umaskfree.CopyOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("CopyDirectoryWith() = %v", err)
+	}
+
+	assertActions(t, actions, map[string]umaskfree.CopyAction{
+		".":         umaskfree.CopyCreated,
+		"file":      umaskfree.CopyCreated,
+		"sub":       umaskfree.CopyCreated,
+		"sub/other": umaskfree.CopyCreated,
+	})
+
+	if _, err := os.Lstat(dst); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("dry run created destination")
+	}
+}
+
+func TestCopyDirectoryWith_preserveTimes(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{"sub/file": "content", "merged/file": "content"})
+	dst := makeTree(t, map[string]string{"merged/existing": "content"})
+
+	names := []string{"sub/file", "sub", "merged/file", "merged"}
+
+	then := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range names {
+		if err := os.Chtimes(filepath.Join(src, name), then, then); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := umaskfree.CopyDirectoryWith(dst, src, umaskfree.CopyOptions{PreserveTimes: true, PreserveOwner: true}); err != nil {
+		t.Fatalf("CopyDirectoryWith() = %v", err)
+	}
+
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(then) {
+			t.Errorf("%q has modification time %v, want %v", name, info.ModTime(), then)
+		}
+	}
+}
+
+// assertActions asserts that actions is equal to want.
+func assertActions(t *testing.T, actions, want map[string]umaskfree.CopyAction) {
+	t.Helper()
+
+	keys := make([]string, 0, len(want)+len(actions))
+	for key := range want {
+		keys = append(keys, key)
+	}
+	for key := range actions {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range slices.Compact(keys) {
+		got, gotOK := actions[key]
+		wanted, wantOK := want[key]
+		if got != wanted || gotOK != wantOK {
+			t.Errorf("%q: got action %v (reported = %v), want %v (reported = %v)", key, got, gotOK, wanted, wantOK)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words context errors path filepath slices sync time pkglib errorsx perf sema status
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/fsx"
+	"go.tkw01536.de/pkglib/perf"
+	"go.tkw01536.de/pkglib/sema"
+	"go.tkw01536.de/pkglib/status"
+)
+
+//spellchecker:words nolint wrapcheck
+
+// Progress describes the progress of [CopyDirectoryParallel].
+type Progress struct {
+	FilesDone  int   // number of files and links that have been processed
+	FilesTotal int   // total number of files and links to process
+	BytesDone  int64 // number of bytes of regular files that have been processed
+	BytesTotal int64 // total number of bytes of regular files to process
+
+	Elapsed time.Duration // time elapsed since the copy was started
+}
+
+// Throughput returns the average number of bytes processed per second.
+// It returns 0 if no time has elapsed.
+func (p Progress) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesDone) / p.Elapsed.Seconds()
+}
+
+// String formats progress as a human-readable line, such as "3/10 files, 1.5 MB/4.0 MB (1.2 MB/s)".
+func (p Progress) String() string {
+	return fmt.Sprintf(
+		"%d/%d files, %s/%s (%s/s)",
+		p.FilesDone, p.FilesTotal,
+		perf.HumanBytes(p.BytesDone), perf.HumanBytes(p.BytesTotal),
+		perf.HumanBytes(int64(p.Throughput())),
+	)
+}
+
+// StatusProgress returns a function that renders progress on the line with the given id of st.
+// It is intended to be used as [ParallelOptions.OnProgress].
+func StatusProgress(st *status.Status, id uint64) func(Progress) {
+	return func(p Progress) {
+		st.Set(id, p.String())
+	}
+}
+
+// DefaultProgressInterval is the default minimum interval between two progress reports.
+const DefaultProgressInterval = 100 * time.Millisecond
+
+// ParallelOptions determine the behavior of [CopyDirectoryParallel].
+type ParallelOptions struct {
+	CopyOptions
+
+	// Concurrency determines the number of files copied at the same time.
+	// When Concurrency.Force is set, an error copying one file does not prevent other files from being copied.
+	Concurrency sema.Concurrency
+
+	// OnProgress, when not nil, is called with the progress of the copy.
+	// It is called at most once per ProgressInterval, and always once after the copy has finished.
+	// Calls to OnProgress (and OnEntry) are never concurrent.
+	OnProgress func(Progress)
+
+	// ProgressInterval is the minimum interval between two calls to OnProgress.
+	// A zero value indicates [DefaultProgressInterval].
+	ProgressInterval time.Duration
+}
+
+// CopyReport describes the result of [CopyDirectoryParallel].
+type CopyReport struct {
+	// Completed holds the entries that were fully processed, in the order in which they were completed.
+	// Excluded entries are not included.
+	Completed []CopyEntry
+
+	// Progress is the final progress of the copy.
+	Progress Progress
+}
+
+// CopyDirectoryParallel is like [CopyDirectoryWith], but copies multiple files concurrently.
+//
+// The source is first walked to create the destination directories and determine the total size of the copy.
+// Files and links are then copied concurrently, as limited by opts.Concurrency.
+//
+// When ctx is canceled, files that have not yet been copied are skipped.
+// Partially copied files that did not previously exist are removed;
+// existing files that were partially overwritten are kept as they are.
+// The returned report holds the entries that were completed, even if an error is returned.
+func CopyDirectoryParallel(ctx context.Context, dst, src string, opts ParallelOptions) (CopyReport, error) {
+	copier := parallelCopier{opts: opts, start: time.Now()}
+	if copier.opts.ProgressInterval == 0 {
+		copier.opts.ProgressInterval = DefaultProgressInterval
+	}
+
+	err := copier.copy(ctx, dst, src)
+	copier.flush()
+
+	return CopyReport{Completed: copier.completed, Progress: copier.progress}, err
+}
+
+// parallelCopier holds the state of [CopyDirectoryParallel].
+type parallelCopier struct {
+	opts  ParallelOptions
+	start time.Time
+
+	m         sync.Mutex // protects the fields below
+	completed []CopyEntry
+	progress  Progress
+	reported  time.Time          // last time OnProgress was called
+	links     map[fileKey]string // destinations of copied files that are hard links in the source
+}
+
+// copy performs the copy.
+func (pc *parallelCopier) copy(ctx context.Context, dst, src string) error {
+	if err := checkDirectoryCopy(fsx.OS, dst, src); err != nil {
+		return err
+	}
+
+	// create directories and find files to be copied.
+	// files that are hard links to a previous file are copied last, as they are linked to the first copy.
+	var files, dirs, deferred []CopyEntry
+	seen := make(map[fileKey]struct{})
+	err := filepath.WalkDir(src, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, current)
+		if err != nil {
+			return fmt.Errorf("failed to determine relative path: %w", err)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat source: %w", err)
+		}
+
+		entry := CopyEntry{Src: current, Dst: filepath.Join(dst, relPath), Info: info}
+
+		if relPath != "." && pc.opts.excluded(filepath.ToSlash(relPath), d.IsDir()) {
+			entry.Action = CopyExcluded
+			pc.done(entry, 0)
+
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// files are copied later
+		if !d.IsDir() {
+			pc.grow(info)
+
+			if key, ok := pc.opts.fileKey(fsx.OS, info); ok {
+				if _, ok := seen[key]; ok {
+					deferred = append(deferred, entry)
+					return nil
+				}
+				seen[key] = struct{}{}
+			}
+
+			files = append(files, entry)
+			return nil
+		}
+
+		entry.Action, err = pc.opts.copyEntry(ctx, fsx.OS, entry.Dst, entry.Src, info, "", nil)
+		if err != nil {
+			return err
+		}
+		if !pc.opts.DryRun {
+			if err := pc.opts.preserve(fsx.OS, entry, &dirs); err != nil {
+				return err
+			}
+		}
+		pc.done(entry, 0)
+		return nil
+	})
+	if err != nil {
+		return err //nolint:wrapcheck // errors are already wrapped
+	}
+
+	// copy all the files
+	group, _ := errorsx.NewGroup(ctx, pc.opts.Concurrency)
+	for _, entry := range files {
+		group.Go(func(ctx context.Context) error {
+			return pc.copyFile(ctx, entry)
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return err //nolint:wrapcheck // errors are already wrapped
+	}
+
+	// link the remaining files
+	for _, entry := range deferred {
+		if err := pc.copyFile(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	// set the times of directories, innermost first
+	for _, entry := range slices.Backward(dirs) {
+		if err := os.Chtimes(entry.Dst, accessTime(entry.Info), entry.Info.ModTime()); err != nil {
+			return fmt.Errorf("failed to preserve times: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies a single file or link.
+// If an earlier copy of a hard link to the same file exists, the file is linked to it instead.
+// If ctx is canceled while a regular file that did not previously exist is being copied, the partially copied file is removed.
+func (pc *parallelCopier) copyFile(ctx context.Context, entry CopyEntry) (err error) {
+	// don't start copying once canceled
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	// check if the file exists, so that an existing file is never removed.
+	_, err = os.Lstat(entry.Dst)
+	created := errors.Is(err, fs.ErrNotExist)
+
+	key, linked := pc.opts.fileKey(fsx.OS, entry.Info)
+	var target string
+	if linked {
+		target = pc.target(key)
+	}
+
+	var written int64
+	entry.Action, err = pc.opts.copyEntry(ctx, fsx.OS, entry.Dst, entry.Src, entry.Info, target, func(n int64) {
+		written += n
+		pc.advance(n)
+	})
+	if cause := context.Cause(ctx); err != nil && cause != nil && errors.Is(err, cause) && created && entry.Info.Mode().IsRegular() && target == "" {
+		if rErr := os.Remove(entry.Dst); rErr != nil && !errors.Is(rErr, fs.ErrNotExist) {
+			return errorsx.Combine(err, fmt.Errorf("failed to remove partially copied file: %w", rErr))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if linked && target == "" && (entry.Action == CopyCreated || entry.Action == CopyOverwritten) {
+		pc.link(key, entry.Dst)
+	}
+
+	if !pc.opts.DryRun && (entry.Action == CopyCreated || entry.Action == CopyOverwritten) {
+		if err := pc.opts.preserve(fsx.OS, entry, nil); err != nil {
+			return err
+		}
+	}
+
+	// files that were skipped (or have changed size) still count as done
+	var remaining int64
+	if entry.Info.Mode().IsRegular() {
+		remaining = entry.Info.Size() - written
+	}
+	pc.done(entry, remaining)
+	return nil
+}
+
+// target returns the destination of the copied file with the given key, if any.
+func (pc *parallelCopier) target(key fileKey) string {
+	pc.m.Lock()
+	defer pc.m.Unlock()
+
+	return pc.links[key]
+}
+
+// link records dst as the destination of the copied file with the given key.
+func (pc *parallelCopier) link(key fileKey, dst string) {
+	pc.m.Lock()
+	defer pc.m.Unlock()
+
+	if pc.links == nil {
+		pc.links = make(map[fileKey]string)
+	}
+	pc.links[key] = dst
+}
+
+// grow adds a file to be copied to the total.
+func (pc *parallelCopier) grow(info fs.FileInfo) {
+	pc.m.Lock()
+	defer pc.m.Unlock()
+
+	pc.progress.FilesTotal++
+	if info.Mode().IsRegular() {
+		pc.progress.BytesTotal += info.Size()
+	}
+}
+
+// advance records that n bytes have been copied.
+func (pc *parallelCopier) advance(n int64) {
+	pc.m.Lock()
+	defer pc.m.Unlock()
+
+	pc.progress.BytesDone += n
+	pc.report(false)
+}
+
+// done records that entry has been processed.
+// remaining is the number of bytes of the entry that were not reported using advance.
+func (pc *parallelCopier) done(entry CopyEntry, remaining int64) {
+	pc.m.Lock()
+	defer pc.m.Unlock()
+
+	pc.opts.report(entry)
+	if entry.Action == CopyExcluded {
+		return
+	}
+
+	pc.completed = append(pc.completed, entry)
+	if !entry.Info.IsDir() {
+		pc.progress.FilesDone++
+		pc.progress.BytesDone += remaining
+	}
+	pc.report(false)
+}
+
+// flush reports the final progress.
+func (pc *parallelCopier) flush() {
+	pc.m.Lock()
+	defer pc.m.Unlock()
+
+	pc.report(true)
+}
+
+// report calls OnProgress unless it was called less than ProgressInterval ago.
+// force forces a call regardless of the interval.
+// The caller must hold m.
+func (pc *parallelCopier) report(force bool) {
+	now := time.Now()
+	pc.progress.Elapsed = now.Sub(pc.start)
+
+	if pc.opts.OnProgress == nil || (!force && now.Sub(pc.reported) < pc.opts.ProgressInterval) {
+		return
+	}
+	pc.reported = now
+	pc.opts.OnProgress(pc.progress)
+}
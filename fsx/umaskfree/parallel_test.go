@@ -0,0 +1,135 @@
+//spellchecker:words umaskfree
+package umaskfree_test
+
+//spellchecker:words context errors path filepath strings testing time pkglib umaskfree sema
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx/umaskfree"
+	"go.tkw01536.de/pkglib/sema"
+)
+
+func ExampleProgress_String() {
+	progress := umaskfree.Progress{
+		FilesDone:  3,
+		FilesTotal: 10,
+		BytesDone:  1_500_000,
+		BytesTotal: 4_000_000,
+		Elapsed:    time.Second,
+	}
+	fmt.Println(progress)
+
+	// Output: 3/10 files, 1.5 MB/4.0 MB (1.5 MB/s)
+}
+
+func TestCopyDirectoryParallel(t *testing.T) {
+	t.Parallel()
+
+	files := make(map[string]string)
+	for i := range 20 {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%3, i)] = strings.Repeat("x", i*100)
+	}
+	src := makeTree(t, files)
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	var calls int
+	var last umaskfree.Progress
+	report, err := umaskfree.CopyDirectoryParallel(t.Context(), dst, src, umaskfree.ParallelOptions{
+		Concurrency: sema.Concurrency{Limit: 4},
+		OnProgress: func(p umaskfree.Progress) {
+			calls++
+			last = p
+		},
+	})
+	if err != nil {
+		t.Fatalf("CopyDirectoryParallel() = %v", err)
+	}
+
+	for name, content := range files {
+		assertFile(t, filepath.Join(dst, filepath.FromSlash(name)), content, 0o644)
+	}
+
+	// 20 files and 4 directories
+	if got := len(report.Completed); got != 24 {
+		t.Errorf("got %d completed entries, want 24", got)
+	}
+
+	want := umaskfree.Progress{FilesDone: 20, FilesTotal: 20, BytesDone: 19000, BytesTotal: 19000}
+	got := report.Progress
+	got.Elapsed = 0
+	if got != want {
+		t.Errorf("got progress %v, want %v", got, want)
+	}
+
+	if calls == 0 || last != report.Progress {
+		t.Errorf("final progress was not reported")
+	}
+}
+
+func TestCopyDirectoryParallel_cancel(t *testing.T) {
+	t.Parallel()
+
+	src := makeTree(t, map[string]string{"a": "a", "sub/b": "b"})
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	report, err := umaskfree.CopyDirectoryParallel(ctx, dst, src, umaskfree.ParallelOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CopyDirectoryParallel() = %v, want %v", err, context.Canceled)
+	}
+	if len(report.Completed) != 0 {
+		t.Errorf("got %d completed entries, want 0", len(report.Completed))
+	}
+	if _, err := os.Lstat(dst); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("canceled copy created destination")
+	}
+}
+
+func TestCopyDirectoryParallel_cancelPartial(t *testing.T) {
+	t.Parallel()
+
+	for _, existing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("existing=%t", existing), func(t *testing.T) {
+			t.Parallel()
+
+			src := makeTree(t, map[string]string{"large": strings.Repeat("x", 1<<20)})
+			dst := filepath.Join(t.TempDir(), "dst")
+			if existing {
+				dst = makeTree(t, map[string]string{"large": "old"})
+			}
+
+			// cancel once the first bytes have been copied
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			_, err := umaskfree.CopyDirectoryParallel(ctx, dst, src, umaskfree.ParallelOptions{
+				CopyOptions:      umaskfree.CopyOptions{Clone: umaskfree.CloneNever},
+				ProgressInterval: time.Nanosecond,
+				OnProgress: func(p umaskfree.Progress) {
+					if p.BytesDone > 0 {
+						cancel()
+					}
+				},
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("CopyDirectoryParallel() = %v, want %v", err, context.Canceled)
+			}
+
+			// partially copied new files are removed, but existing files are kept
+			_, err = os.Lstat(filepath.Join(dst, "large"))
+			if removed := errors.Is(err, fs.ErrNotExist); removed == existing {
+				t.Errorf("partially copied file removed = %t, want %t", removed, !existing)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !unix
+
+//spellchecker:words umaskfree
+package umaskfree
+
+import (
+	"io/fs"
+	"os"
+)
+
+//spellchecker:words chown lchown
+
+// chownLike is a no-op on operating systems without file ownership.
+func chownLike(file *os.File, info fs.FileInfo) error {
+	return nil
+}
+
+// lchownLike is a no-op on operating systems without file ownership.
+func lchownLike(path string, info fs.FileInfo) error {
+	return nil
+}
+
+// fileKey uniquely identifies a file on the system.
+type fileKey struct{}
+
+// hardlinkKey is not supported on this operating system, and always returns false.
+func hardlinkKey(info fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
+
+// syncDir is a no-op on operating systems that do not support syncing directories.
+func syncDir(path string) error {
+	return nil
+}
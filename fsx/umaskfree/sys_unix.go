@@ -0,0 +1,72 @@
+//go:build unix
+
+//spellchecker:words umaskfree
+package umaskfree
+
+//spellchecker:words errors syscall pkglib errorsx
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+//spellchecker:words chown lchown fsync nosec nolint unconvert
+
+// chownLike changes the owner and group of file to those of info.
+func chownLike(file *os.File, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := file.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+		return fmt.Errorf("failed to chown: %w", err)
+	}
+	return nil
+}
+
+// lchownLike changes the owner and group of path to those of info, without following symlinks.
+// Errors caused by insufficient permissions are ignored.
+func lchownLike(path string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	err := os.Lchown(path, int(stat.Uid), int(stat.Gid))
+	if err == nil || errors.Is(err, fs.ErrPermission) {
+		return nil
+	}
+	return fmt.Errorf("failed to chown: %w", err)
+}
+
+// fileKey uniquely identifies a file on the system.
+type fileKey struct {
+	dev, ino uint64
+}
+
+// hardlinkKey returns the key of the file described by info.
+// It returns false if the file has only a single link.
+func hardlinkKey(info fs.FileInfo) (fileKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink <= 1 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(stat.Dev), ino: stat.Ino}, true //nolint:unconvert // type of Dev depends on the platform
+}
+
+// syncDir calls fsync on the given directory.
+func syncDir(path string) (e error) {
+	dir, err := os.Open(path) // #nosec G304 -- path is an explicit parameter
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer errorsx.Close(dir, &e, "directory")
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
@@ -13,30 +13,18 @@ import (
 	"time"
 
 	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/fsx"
 )
 
-//spellchecker:words nosec wrapcheck
+//spellchecker:words nolint wrapcheck forcetypeassert
 
 // Create is like [os.Create] with an additional mode argument.
 func Create(path string, mode fs.FileMode) (*os.File, error) {
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode) // #nosec G304 -- path is an explicit parameter
+	file, err := fsx.OS.Create(path, mode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+		return nil, err //nolint:wrapcheck // errors returned by WritableFS are already wrapped
 	}
-
-	if err := file.Chmod(mode); err != nil {
-		err = fmt.Errorf("failed to chmod file: %w", err)
-
-		// close the file
-		if closeErr := file.Close(); closeErr != nil {
-			closeErr = fmt.Errorf("failed to close erroneous file: %w", err)
-			err = errors.Join(err, closeErr)
-		}
-
-		return nil, err
-	}
-
-	return file, nil
+	return file.(*os.File), nil //nolint:forcetypeassert // files created by fsx.OS are always *os.File
 }
 
 // WriteFile is like [os.WriteFile].
@@ -64,15 +52,20 @@ const DefaultFilePerm fs.FileMode = 0666
 // If the file does not exist, it is created using [Create].
 // If the file does exist, its' access and modification times are updated to the current time.
 func Touch(path string, perm fs.FileMode) error {
+	return TouchFS(fsx.OS, path, perm)
+}
+
+// TouchFS is like [Touch], but operates on fsys.
+func TouchFS(fsys fsx.WritableFS, path string, perm fs.FileMode) error {
 	if perm == 0 {
 		perm = DefaultFilePerm
 	}
-	_, err := os.Stat(path)
+	_, err := fsys.Stat(path)
 	switch {
 	case errors.Is(err, fs.ErrNotExist):
-		f, err := Create(path, perm)
+		f, err := fsys.Create(path, perm)
 		if err != nil {
-			return err
+			return err //nolint:wrapcheck // errors returned by WritableFS are already wrapped
 		}
 		if err := f.Close(); err != nil {
 			return fmt.Errorf("failed to close file: %w", err)
@@ -82,7 +75,7 @@ func Touch(path string, perm fs.FileMode) error {
 		return fmt.Errorf("failed to stat path: %w", err)
 	default:
 		now := time.Now().Local()
-		if err := os.Chtimes(path, now, now); err != nil {
+		if err := fsys.Chtimes(path, now, now); err != nil {
 			return fmt.Errorf("failed to change file time: %w", err)
 		}
 		return nil
@@ -0,0 +1,213 @@
+package fsx
+
+//spellchecker:words context path filepath runtime slices strings sync pkglib errorsx sema
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/perf"
+	"go.tkw01536.de/pkglib/sema"
+)
+
+// UsageOptions determine the behavior of [Usage].
+type UsageOptions struct {
+	// OneFileSystem indicates that entries on a different file system than the root should be skipped.
+	// Such entries are neither counted nor traversed.
+	OneFileSystem bool
+
+	// Largest is the number of largest files to report.
+	Largest int
+
+	// Concurrency determines the number of directories read at the same time.
+	// A non-positive limit uses [runtime.GOMAXPROCS].
+	// When Concurrency.Force is set, errors reading one directory do not prevent reading other directories.
+	Concurrency sema.Concurrency
+}
+
+// UsageEntry is a single file reported by [Usage].
+type UsageEntry struct {
+	Path      string // path of the file, starting with the path passed to Usage
+	Apparent  int64  // size of the file
+	Allocated int64  // number of bytes allocated on disk for the file
+}
+
+// DiskUsage describes the disk usage of a directory tree, as returned by [Usage].
+type DiskUsage struct {
+	// Apparent is the total size of all regular files.
+	// Allocated is the number of bytes allocated on disk for all entries, including directories and links.
+	// Where the operating system does not report allocations, it is the size of all entries.
+	Apparent  int64
+	Allocated int64
+
+	// Files, Directories and Symlinks count the number of entries of each type.
+	// Entries of other types, such as devices or sockets, are not counted.
+	Files       int
+	Directories int
+	Symlinks    int
+
+	// Largest holds the largest files in the tree, sorted by decreasing apparent size.
+	// It holds at most [UsageOptions.Largest] entries.
+	Largest []UsageEntry
+}
+
+// String formats the usage in human-readable form, using [perf.HumanBytes].
+// For example: "3 files, 2 directories, 1 symlinks: 1.5 MB (2.0 MB on disk)".
+func (usage DiskUsage) String() string {
+	return fmt.Sprintf(
+		"%d files, %d directories, %d symlinks: %s (%s on disk)",
+		usage.Files, usage.Directories, usage.Symlinks,
+		perf.HumanBytes(usage.Apparent), perf.HumanBytes(usage.Allocated),
+	)
+}
+
+// Usage determines the disk usage of the tree rooted at path.
+// Symbolic links are not followed, including when path itself is a symbolic link.
+//
+// Files with multiple hard links inside the tree are only counted once.
+// Directories are read concurrently, as determined by opts.
+func Usage(path string, opts UsageOptions) (DiskUsage, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return DiskUsage{}, fmt.Errorf("failed to stat root: %w", err)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency.Limit <= 0 {
+		concurrency.Limit = runtime.GOMAXPROCS(0)
+	}
+
+	counter := usageCounter{
+		opts:  opts,
+		links: make(map[usageKey]struct{}),
+	}
+	counter.device, _ = statUsage(info)
+
+	group, _ := errorsx.NewGroup(context.Background(), concurrency)
+	counter.visit(group, path, info)
+	err = group.Wait()
+
+	counter.usage.Largest = slices.Clip(counter.usage.Largest)
+	return counter.usage, err
+}
+
+// usageKey uniquely identifies a file on the system.
+type usageKey struct {
+	dev, ino uint64
+}
+
+// usageStat holds system-specific information about a file.
+type usageStat struct {
+	allocated int64    // number of bytes allocated on disk, or -1 if unknown
+	key       usageKey // key identifying the file
+	linked    bool     // file has more than one link
+}
+
+// usageCounter holds the state of [Usage].
+type usageCounter struct {
+	opts   UsageOptions
+	device uint64 // device of the root
+
+	m     sync.Mutex
+	usage DiskUsage
+	links map[usageKey]struct{} // files with multiple links that have already been counted
+}
+
+// visit counts the entry at path and schedules reading it, if it is a directory.
+func (counter *usageCounter) visit(group *errorsx.Group, path string, info fs.FileInfo) {
+	dev, stat := statUsage(info)
+	if counter.opts.OneFileSystem && dev != counter.device {
+		return
+	}
+	if !counter.count(path, info, stat) || !info.IsDir() {
+		return
+	}
+
+	group.Go(func(ctx context.Context) error {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil && !counter.opts.Concurrency.Force {
+				return nil
+			}
+
+			child := filepath.Join(path, entry.Name())
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("failed to stat %q: %w", child, err)
+			}
+			counter.visit(group, child, info)
+		}
+		return nil
+	})
+}
+
+// count adds the entry at path to the usage.
+// Returns false if the entry was already counted as a hard link.
+func (counter *usageCounter) count(path string, info fs.FileInfo, stat usageStat) bool {
+	counter.m.Lock()
+	defer counter.m.Unlock()
+
+	if stat.linked {
+		if _, ok := counter.links[stat.key]; ok {
+			return false
+		}
+		counter.links[stat.key] = struct{}{}
+	}
+
+	allocated := stat.allocated
+	if allocated < 0 {
+		allocated = info.Size()
+	}
+	counter.usage.Allocated += allocated
+
+	switch {
+	case info.IsDir():
+		counter.usage.Directories++
+	case info.Mode()&fs.ModeSymlink != 0:
+		counter.usage.Symlinks++
+	case info.Mode().IsRegular():
+		counter.usage.Files++
+		counter.usage.Apparent += info.Size()
+		counter.largest(UsageEntry{Path: path, Apparent: info.Size(), Allocated: allocated})
+	}
+	return true
+}
+
+// largest records entry as one of the largest entries, if applicable.
+// Must be called while holding the lock.
+func (counter *usageCounter) largest(entry UsageEntry) {
+	n := counter.opts.Largest
+	if n <= 0 {
+		return
+	}
+
+	// find the position to insert at, keeping equal sizes sorted by path for deterministic output
+	largest := counter.usage.Largest
+	index, _ := slices.BinarySearchFunc(largest, entry, func(have, want UsageEntry) int {
+		if have.Apparent != want.Apparent {
+			return cmp.Compare(want.Apparent, have.Apparent)
+		}
+		return strings.Compare(have.Path, want.Path)
+	})
+	if index >= n {
+		return
+	}
+
+	largest = slices.Insert(largest, index, entry)
+	if len(largest) > n {
+		largest = largest[:n]
+	}
+	counter.usage.Largest = largest
+}
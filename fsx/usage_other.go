@@ -0,0 +1,11 @@
+//go:build !unix
+
+package fsx
+
+import "io/fs"
+
+// statUsage returns the device of the file described by info, and information used for computing disk usage.
+// On this operating system, the device is always zero and allocations are not known.
+func statUsage(info fs.FileInfo) (dev uint64, stat usageStat) {
+	return 0, usageStat{allocated: -1}
+}
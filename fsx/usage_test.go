@@ -0,0 +1,81 @@
+package fsx_test
+
+//spellchecker:words path filepath strings testing pkglib sema
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/fsx"
+	"go.tkw01536.de/pkglib/sema"
+)
+
+func ExampleDiskUsage_String() {
+	usage := fsx.DiskUsage{
+		Apparent:    1_500_000,
+		Allocated:   2_000_000,
+		Files:       3,
+		Directories: 2,
+		Symlinks:    1,
+	}
+	fmt.Println(usage)
+	// Output: 3 files, 2 directories, 1 symlinks: 1.5 MB (2.0 MB on disk)
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for name, size := range map[string]int{"small": 10, "a/medium": 1000, "a/b/large": 100_000, "a/b/c/tiny": 1} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("small", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Link(filepath.Join(root, "a", "b", "large"), filepath.Join(root, "hardlink")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	for _, limit := range []int{0, 1} {
+		usage, err := fsx.Usage(root, fsx.UsageOptions{Largest: 2, Concurrency: sema.Concurrency{Limit: limit}})
+		if err != nil {
+			t.Fatalf("Usage() = %v", err)
+		}
+
+		if usage.Files != 4 || usage.Directories != 4 || usage.Symlinks != 1 {
+			t.Errorf("Usage() counted %d files, %d directories, %d symlinks, want 4, 4, 1", usage.Files, usage.Directories, usage.Symlinks)
+		}
+		if want := int64(10 + 1000 + 100_000 + 1); usage.Apparent != want {
+			t.Errorf("Usage() = %d apparent bytes, want %d", usage.Apparent, want)
+		}
+		if usage.Allocated <= 0 {
+			t.Errorf("Usage() = %d allocated bytes, want positive", usage.Allocated)
+		}
+
+		var largest []string
+		for _, entry := range usage.Largest {
+			rel, err := filepath.Rel(root, entry.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			largest = append(largest, fmt.Sprintf("%s:%d", filepath.ToSlash(rel), entry.Apparent))
+		}
+		// the hard link is only counted once, so only one of its names is reported
+		if got := strings.Join(largest, ","); got != "a/b/large:100000,a/medium:1000" && got != "hardlink:100000,a/medium:1000" {
+			t.Errorf("Usage() reported largest %q", got)
+		}
+	}
+
+	// a missing root is an error
+	if _, err := fsx.Usage(filepath.Join(root, "missing"), fsx.UsageOptions{}); err == nil {
+		t.Error("Usage(missing) succeeded, want an error")
+	}
+}
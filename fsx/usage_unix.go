@@ -0,0 +1,26 @@
+//go:build unix
+
+package fsx
+
+//spellchecker:words syscall
+import (
+	"io/fs"
+	"syscall"
+)
+
+//spellchecker:words nolint unconvert nosec
+
+// statUsage returns the device of the file described by info, and information used for computing disk usage.
+func statUsage(info fs.FileInfo) (dev uint64, stat usageStat) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, usageStat{allocated: -1}
+	}
+
+	dev = uint64(sys.Dev) //nolint:unconvert // type of Dev depends on the platform
+	return dev, usageStat{
+		allocated: int64(sys.Blocks) * 512,                  //nolint:unconvert // type of Blocks depends on the platform
+		key:       usageKey{dev: dev, ino: uint64(sys.Ino)}, //nolint:unconvert // type of Ino depends on the platform
+		linked:    info.Mode().IsRegular() && sys.Nlink > 1,
+	}
+}
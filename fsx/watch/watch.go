@@ -0,0 +1,349 @@
+// Package watch provides notifications about changes to the file system.
+//
+// It is currently only supported on Linux, using inotify.
+//
+//spellchecker:words watch
+package watch
+
+//spellchecker:words context errors iter path filepath slices strings sync time
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+//spellchecker:words inotify
+
+// Op is a set of operations that caused an event.
+type Op uint8
+
+const (
+	Create   Op = 1 << iota // a file or directory was created, or moved into a watched directory
+	Write                   // a file was written to
+	Remove                  // a file or directory was removed
+	Rename                  // a file or directory was moved away
+	Chmod                   // metadata, such as permissions or timestamps, changed
+	Overflow                // the kernel dropped events; any watched tree should be scanned again
+)
+
+// AllOps holds all operations, except [Overflow].
+const AllOps = Create | Write | Remove | Rename | Chmod
+
+// Has checks if op contains all operations in other.
+func (op Op) Has(other Op) bool {
+	return op&other == other
+}
+
+// String returns the names of all operations in op, separated by "|".
+func (op Op) String() string {
+	names := make([]string, 0, 6)
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{
+		{Create, "create"},
+		{Write, "write"},
+		{Remove, "remove"},
+		{Rename, "rename"},
+		{Chmod, "chmod"},
+		{Overflow, "overflow"},
+	} {
+		if op.Has(o.op) {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Event describes a change to the file system.
+type Event struct {
+	Path string // path of the changed file, starting with the path that was added to the watcher
+	Op   Op     // operations that happened to the file
+}
+
+func (event Event) String() string {
+	return event.Op.String() + " " + event.Path
+}
+
+// DefaultDebounce is the default time to wait for further events before delivering a batch.
+const DefaultDebounce = 100 * time.Millisecond
+
+// Options determine the behavior of a [Watcher].
+type Options struct {
+	// Recursive indicates that directories should be watched recursively.
+	// Subdirectories created after the watch was added are watched automatically,
+	// and create events are generated for any content they already have.
+	Recursive bool
+
+	// Ops determines the operations to report.
+	// A zero value indicates [AllOps].
+	// Events with the [Overflow] operation are always reported.
+	Ops Op
+
+	// Include and Exclude hold glob patterns, using the syntax of [filepath.Match].
+	// Patterns without a path separator are matched against the base name of a path, other patterns against the full path.
+	//
+	// If Include is non-empty, only events for paths matching at least one pattern are reported.
+	// Events for paths matching any Exclude pattern are not reported.
+	// Excluded directories are also not watched recursively.
+	Include []string
+	Exclude []string
+
+	// Debounce is the time to wait for further events before delivering a batch of events.
+	// Each new event restarts the wait.
+	// A zero value indicates [DefaultDebounce], a negative value delivers each event in its own batch.
+	Debounce time.Duration
+}
+
+// excluded checks if path is excluded.
+func (opts Options) excluded(path string) bool {
+	return slices.ContainsFunc(opts.Exclude, func(pattern string) bool { return matchPattern(pattern, path) })
+}
+
+// reports checks if event should be reported.
+func (opts Options) reports(event Event) bool {
+	if event.Op.Has(Overflow) {
+		return true
+	}
+
+	ops := opts.Ops
+	if ops == 0 {
+		ops = AllOps
+	}
+	if event.Op&ops == 0 {
+		return false
+	}
+
+	if opts.excluded(event.Path) {
+		return false
+	}
+	return len(opts.Include) == 0 || slices.ContainsFunc(opts.Include, func(pattern string) bool { return matchPattern(pattern, event.Path) })
+}
+
+// matchPattern checks if path matches pattern.
+// An invalid pattern never matches.
+func matchPattern(pattern, path string) bool {
+	name := path
+	if !strings.ContainsRune(pattern, filepath.Separator) {
+		name = filepath.Base(path)
+	}
+	matched, err := filepath.Match(pattern, name)
+	return err == nil && matched
+}
+
+// ErrClosed is returned when adding a path to a closed watcher.
+var ErrClosed = errors.New("watcher closed")
+
+// Watcher watches files and directories for changes.
+// It must be created using [New].
+//
+// Events are debounced and delivered in batches on the channel returned by [Watcher.Events].
+type Watcher struct {
+	opts    Options
+	backend backend
+
+	raw    chan Event    // events produced by the backend
+	events chan []Event  // batches of events
+	done   chan struct{} // closed when Close is called
+	err    error         // error that stopped the backend, set before events is closed
+
+	closeOnce sync.Once
+	closeErr  error
+	wg        sync.WaitGroup
+}
+
+// backend watches the file system.
+type backend interface {
+	// add starts watching path.
+	add(path string) error
+
+	// run reads events and passes them to emit until the backend is closed.
+	run(emit func(Event)) error
+
+	// close closes the backend, causing run to return.
+	close() error
+}
+
+// New creates a new watcher and starts watching the given paths.
+// See [Watcher.Add] for details.
+//
+// The watcher should be closed using [Watcher.Close] once it is no longer needed.
+func New(opts Options, paths ...string) (*Watcher, error) {
+	b, err := newBackend(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		opts:    opts,
+		backend: b,
+
+		raw:    make(chan Event),
+		events: make(chan []Event),
+		done:   make(chan struct{}),
+	}
+
+	for _, path := range paths {
+		if err := w.Add(path); err != nil {
+			return nil, errors.Join(err, b.close())
+		}
+	}
+
+	w.wg.Go(w.read)
+	w.wg.Go(w.batch)
+	return w, nil
+}
+
+// Add starts watching path.
+// If path is a directory, changes to its direct content are reported, or to all its content when watching recursively.
+// Otherwise, changes to path itself are reported.
+func (w *Watcher) Add(path string) error {
+	select {
+	case <-w.done:
+		return ErrClosed
+	default:
+	}
+	return w.backend.add(path)
+}
+
+// Events returns a channel that receives batches of events.
+// The channel is closed once the watcher is closed, or if watching fails.
+// In the latter case, [Watcher.Err] returns the error.
+//
+// Within a batch, each path occurs only once, with the operations of all its events combined.
+func (w *Watcher) Events() <-chan []Event {
+	return w.events
+}
+
+// Err returns the error that caused the watcher to stop.
+// It may only be called after the channel returned by [Watcher.Events] has been closed.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+// Close stops watching and closes the channel returned by [Watcher.Events].
+// Pending events are discarded.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.closeErr = w.backend.close()
+		w.wg.Wait()
+	})
+	return w.closeErr
+}
+
+// emit passes event to the batching goroutine, if it should be reported.
+func (w *Watcher) emit(event Event) {
+	if !w.opts.reports(event) {
+		return
+	}
+	select {
+	case w.raw <- event:
+	case <-w.done:
+	}
+}
+
+// read runs the backend until it is closed.
+func (w *Watcher) read() {
+	defer close(w.raw)
+
+	if err := w.backend.run(w.emit); err != nil {
+		w.err = fmt.Errorf("failed to read events: %w", err)
+	}
+}
+
+// batch collects events into batches and delivers them.
+func (w *Watcher) batch() {
+	defer close(w.events)
+
+	debounce := w.opts.Debounce
+	if debounce == 0 {
+		debounce = DefaultDebounce
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	var pending []Event
+	for {
+		select {
+		case event, ok := <-w.raw:
+			if !ok {
+				w.deliver(pending)
+				return
+			}
+
+			pending = merge(pending, event)
+			if debounce < 0 {
+				w.deliver(pending)
+				pending = nil
+				continue
+			}
+			timer.Reset(debounce)
+		case <-timer.C:
+			w.deliver(pending)
+			pending = nil
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// merge adds event to batch, combining it with an existing event for the same path.
+func merge(batch []Event, event Event) []Event {
+	index := slices.IndexFunc(batch, func(e Event) bool { return e.Path == event.Path })
+	if index < 0 {
+		return append(batch, event)
+	}
+	batch[index].Op |= event.Op
+	return batch
+}
+
+// deliver delivers a non-empty batch of events, unless the watcher is closed.
+func (w *Watcher) deliver(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+	select {
+	case w.events <- batch:
+	case <-w.done:
+	}
+}
+
+// Watch creates a new watcher for the given paths, and returns an iterator over its batches of events.
+// The watcher is closed once iteration stops, or ctx is canceled.
+//
+// If the watcher cannot be created or stops because of an error, the error is yielded along with a nil batch.
+func Watch(ctx context.Context, opts Options, paths ...string) iter.Seq2[[]Event, error] {
+	return func(yield func([]Event, error) bool) {
+		w, err := New(opts, paths...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer func() { _ = w.Close() }() // nothing to report the error to
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case batch, ok := <-w.Events():
+				if !ok {
+					if err := w.Err(); err != nil {
+						yield(nil, err)
+					}
+					return
+				}
+				if !yield(batch, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+//spellchecker:words watch
+package watch
+
+//spellchecker:words bytes encoding binary errors path filepath strings sync golang unix
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+//spellchecker:words inotify cloexec nonblock isdir nosec nolint wrapcheck
+
+// inotifyMask is the mask of events to watch for.
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_DELETE_SELF |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MOVE_SELF
+
+// inotify is a backend using the Linux inotify api.
+type inotify struct {
+	opts Options
+	fd   int
+	file *os.File // wraps fd to make use of the runtime poller
+
+	m     sync.Mutex          // protects the fields below
+	paths map[int]string      // paths of watch descriptors
+	roots map[string]struct{} // paths added explicitly
+}
+
+func newBackend(opts Options) (backend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+
+	return &inotify{
+		opts: opts,
+		fd:   fd,
+		file: os.NewFile(uintptr(fd), "inotify"),
+
+		paths: make(map[int]string),
+		roots: make(map[string]struct{}),
+	}, nil
+}
+
+func (in *inotify) add(path string) error {
+	path = filepath.Clean(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %q: %w", path, err)
+	}
+
+	in.m.Lock()
+	in.roots[path] = struct{}{}
+	in.m.Unlock()
+
+	if !info.IsDir() || !in.opts.Recursive {
+		return in.watch(path)
+	}
+	return in.watchTree(path, nil)
+}
+
+// watchTree watches the directory root and all directories below it.
+// If emit is not nil, it is called with create events for all entries below root.
+func (in *inotify) watchTree(root string, emit func(Event)) error {
+	err := filepath.WalkDir(root, func(current string, d fs.DirEntry, err error) error {
+		// entries may disappear while walking
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if current != root {
+			if in.opts.excluded(current) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if emit != nil {
+				emit(Event{Path: current, Op: Create})
+			}
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+		if err := in.watch(current); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch %q: %w", root, err)
+	}
+	return nil
+}
+
+// watch adds a watch for path.
+func (in *inotify) watch(path string) error {
+	wd, err := unix.InotifyAddWatch(in.fd, path, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("failed to watch %q: %w", path, err)
+	}
+
+	in.m.Lock()
+	defer in.m.Unlock()
+
+	in.paths[wd] = path
+	return nil
+}
+
+// forget removes the watch for path and all watches below it.
+// If remove is true, the watches are also removed from the kernel.
+func (in *inotify) forget(path string, remove bool) {
+	in.m.Lock()
+	defer in.m.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	for wd, p := range in.paths {
+		if p != path && !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if _, ok := in.roots[p]; ok && p != path {
+			continue
+		}
+
+		if remove {
+			_, _ = unix.InotifyRmWatch(in.fd, uint32(wd)) // #nosec G115 -- watch descriptors are non-negative
+		}
+		delete(in.paths, wd)
+		delete(in.roots, p)
+	}
+}
+
+// sizeofEvent is the size of the fixed part of an inotify event.
+const sizeofEvent = unix.SizeofInotifyEvent
+
+func (in *inotify) run(emit func(Event)) error {
+	buffer := make([]byte, 4096*(sizeofEvent+unix.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buffer)
+		if errors.Is(err, os.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped by the caller
+		}
+
+		data := buffer[:n]
+		for len(data) >= sizeofEvent {
+			wd := int(int32(binary.NativeEndian.Uint32(data[0:4]))) // #nosec G115 -- kernel provides a signed integer
+			mask := binary.NativeEndian.Uint32(data[4:8])
+			length := int(binary.NativeEndian.Uint32(data[12:16]))
+
+			name := string(bytes.TrimRight(data[sizeofEvent:sizeofEvent+length], "\x00"))
+			data = data[sizeofEvent+length:]
+
+			in.handle(wd, mask, name, emit)
+		}
+	}
+}
+
+// handle handles a single inotify event.
+func (in *inotify) handle(wd int, mask uint32, name string, emit func(Event)) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		emit(Event{Op: Overflow})
+		return
+	}
+
+	in.m.Lock()
+	path, ok := in.paths[wd]
+	_, isRoot := in.roots[path]
+	in.m.Unlock()
+	if !ok {
+		return
+	}
+
+	if mask&unix.IN_IGNORED != 0 {
+		in.forget(path, false)
+		return
+	}
+
+	if name != "" {
+		path = filepath.Join(path, name)
+	}
+
+	var op Op
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		op |= Create
+	}
+	if mask&unix.IN_MODIFY != 0 {
+		op |= Write
+	}
+	if mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0 {
+		op |= Remove
+	}
+	if mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0 {
+		op |= Rename
+	}
+	if mask&unix.IN_ATTRIB != 0 {
+		op |= Chmod
+	}
+
+	// watch new directories before reporting them, so that no events inside them are lost.
+	var content []Event
+	if in.opts.Recursive && mask&unix.IN_ISDIR != 0 && op.Has(Create) && !in.opts.excluded(path) {
+		// the directory may already be gone; nothing to watch in that case.
+		_ = in.watchTree(path, func(event Event) { content = append(content, event) })
+	}
+
+	emit(Event{Path: path, Op: op})
+	for _, event := range content {
+		emit(event)
+	}
+
+	// a watched directory (other than an explicitly added one) was moved away.
+	// the watches of its content no longer have the right paths.
+	if mask&unix.IN_MOVE_SELF != 0 && !isRoot {
+		in.forget(path, true)
+	}
+}
+
+func (in *inotify) close() error {
+	if err := in.file.Close(); err != nil {
+		return fmt.Errorf("failed to close inotify: %w", err)
+	}
+	return nil
+}
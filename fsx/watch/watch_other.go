@@ -0,0 +1,15 @@
+//go:build !linux
+
+//spellchecker:words watch
+package watch
+
+//spellchecker:words errors
+import (
+	"errors"
+	"fmt"
+)
+
+// newBackend returns an error, as watching is not supported on this operating system.
+func newBackend(opts Options) (backend, error) {
+	return nil, fmt.Errorf("failed to create watcher: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+//spellchecker:words watch
+package watch_test
+
+//spellchecker:words context path filepath testing time pkglib
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.tkw01536.de/pkglib/fsx/watch"
+)
+
+// collect receives batches from w until want holds for the received events, or a timeout occurs.
+func collect(t *testing.T, w *watch.Watcher, want map[string]watch.Op) map[string]watch.Op {
+	t.Helper()
+
+	got := make(map[string]watch.Op)
+	timeout := time.After(5 * time.Second)
+	for {
+		done := true
+		for path, op := range want {
+			if !got[path].Has(op) {
+				done = false
+			}
+		}
+		if done {
+			return got
+		}
+
+		select {
+		case batch, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("watcher stopped: %v", w.Err())
+			}
+			for _, event := range batch {
+				got[event.Path] |= event.Op
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for events: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWatcher_recursive(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	w, err := watch.New(watch.Options{Recursive: true, Exclude: []string{"*.tmp"}, Debounce: 10 * time.Millisecond}, root)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer func() {
+		if err := w.Close(); err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	}()
+
+	// create a new directory, and a file inside it
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	collect(t, w, map[string]watch.Op{sub: watch.Create})
+
+	file := filepath.Join(sub, "file.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "ignored.tmp"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(file, filepath.Join(root, "moved.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, w, map[string]watch.Op{
+		file:                             watch.Create | watch.Write | watch.Chmod | watch.Rename,
+		filepath.Join(root, "moved.txt"): watch.Create,
+	})
+	if _, ok := got[filepath.Join(sub, "ignored.tmp")]; ok {
+		t.Errorf("got event for excluded file")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = os.WriteFile(filepath.Join(root, "other.log"), nil, 0o600)
+		_ = os.WriteFile(filepath.Join(root, "config.yaml"), nil, 0o600)
+	}()
+
+	for batch, err := range watch.Watch(ctx, watch.Options{Include: []string{"*.yaml"}, Ops: watch.Create}, root) {
+		if err != nil {
+			t.Fatalf("Watch() yielded error %v", err)
+		}
+		for _, event := range batch {
+			if event.Path != filepath.Join(root, "config.yaml") || event.Op != watch.Create {
+				t.Fatalf("got unexpected event %v", event)
+			}
+		}
+		return
+	}
+	t.Fatalf("Watch() stopped without events: %v", ctx.Err())
+}
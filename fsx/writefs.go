@@ -0,0 +1,264 @@
+package fsx
+
+//spellchecker:words errors path filepath strings time
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//spellchecker:words nosec
+
+// WritableFS is a file system that can be read from and written to.
+//
+// The names accepted depend on the implementation.
+// [OS] accepts operating system paths, while [MemFS] requires names valid according to [fs.ValidPath].
+// [ReadOnly] converts any name into a valid one.
+//
+// Methods creating files or directories use the exact permissions given, regardless of the umask.
+// Errors returned should wrap an [*fs.PathError].
+type WritableFS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadLinkFS
+
+	// Mkdir creates a new directory with the given permissions.
+	Mkdir(name string, perm fs.FileMode) error
+
+	// Create creates or truncates the named file.
+	// A newly created file receives the given permissions.
+	Create(name string, perm fs.FileMode) (WritableFile, error)
+
+	// Symlink creates newname as a symbolic link to oldname.
+	Symlink(oldname, newname string) error
+
+	// Chmod changes the mode of the named file, following symbolic links.
+	Chmod(name string, mode fs.FileMode) error
+
+	// Chtimes changes the access and modification times of the named file, following symbolic links.
+	Chtimes(name string, atime, mtime time.Time) error
+
+	// Rename renames oldname to newname, replacing newname if it already exists and is not a directory.
+	Rename(oldname, newname string) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+}
+
+// WritableFile is a file opened for writing by [WritableFS.Create].
+type WritableFile interface {
+	fs.File
+	io.Writer
+}
+
+// OS is a [WritableFS] backed by the operating system.
+// Files returned by its Create method are of type [*os.File].
+var OS WritableFS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	file, err := os.Open(name) // #nosec G304 -- name is an explicit parameter
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, nil
+}
+
+func (osFS) Lstat(name string) (fs.FileInfo, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, nil
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	return entries, nil
+}
+
+func (osFS) ReadLink(name string) (string, error) {
+	target, err := os.Readlink(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read link: %w", err)
+	}
+	return target, nil
+}
+
+func (osFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := os.Mkdir(name, perm); err != nil {
+		return fmt.Errorf("failed to make directory: %w", err)
+	}
+	if err := os.Chmod(name, perm); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
+	}
+	return nil
+}
+
+func (osFS) Create(name string, perm fs.FileMode) (WritableFile, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm) // #nosec G304 -- name is an explicit parameter
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := file.Chmod(perm); err != nil {
+		err = fmt.Errorf("failed to chmod file: %w", err)
+		if closeErr := file.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close erroneous file: %w", closeErr))
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (osFS) Symlink(oldname, newname string) error {
+	if err := os.Symlink(oldname, newname); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	return nil
+}
+
+func (osFS) Chmod(name string, mode fs.FileMode) error {
+	if err := os.Chmod(name, mode); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
+	}
+	return nil
+}
+
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := os.Chtimes(name, atime, mtime); err != nil {
+		return fmt.Errorf("failed to change times: %w", err)
+	}
+	return nil
+}
+
+func (osFS) Rename(oldname, newname string) error {
+	if err := os.Rename(oldname, newname); err != nil {
+		return fmt.Errorf("failed to rename: %w", err)
+	}
+	return nil
+}
+
+func (osFS) Remove(name string) error {
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove: %w", err)
+	}
+	return nil
+}
+
+// ReadOnly adapts fsys into a [WritableFS] that can not be written to.
+// All methods modifying the file system return an error wrapping [fs.ErrPermission].
+//
+// Names are converted into paths valid for fsys by making them slash-separated and relative.
+// Symbolic links are only supported if fsys implements [fs.ReadLinkFS].
+func ReadOnly(fsys fs.FS) WritableFS {
+	return readOnlyFS{fsys: fsys}
+}
+
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+// validPath turns name into a path valid for an [fs.FS].
+func validPath(name string) string {
+	name = filepath.ToSlash(strings.TrimPrefix(name, filepath.VolumeName(name)))
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// fsName turns name into a path valid for an [fs.FS], with the empty path being ".".
+func fsName(name string) string {
+	if name := validPath(name); name != "" {
+		return name
+	}
+	return "."
+}
+
+func (ro readOnlyFS) Open(name string) (fs.File, error) {
+	file, err := ro.fsys.Open(fsName(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+func (ro readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(ro.fsys, fsName(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, nil
+}
+
+func (ro readOnlyFS) Lstat(name string) (fs.FileInfo, error) {
+	info, err := fs.Lstat(ro.fsys, fsName(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, nil
+}
+
+func (ro readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(ro.fsys, fsName(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	return entries, nil
+}
+
+func (ro readOnlyFS) ReadLink(name string) (string, error) {
+	target, err := fs.ReadLink(ro.fsys, fsName(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read link: %w", err)
+	}
+	return target, nil
+}
+
+// readOnly returns the error for a modifying operation.
+func (readOnlyFS) readOnly(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+func (ro readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return ro.readOnly("mkdir", name)
+}
+
+func (ro readOnlyFS) Create(name string, perm fs.FileMode) (WritableFile, error) {
+	return nil, ro.readOnly("open", name)
+}
+
+func (ro readOnlyFS) Symlink(oldname, newname string) error {
+	return ro.readOnly("symlink", newname)
+}
+
+func (ro readOnlyFS) Chmod(name string, mode fs.FileMode) error {
+	return ro.readOnly("chmod", name)
+}
+
+func (ro readOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	return ro.readOnly("chtimes", name)
+}
+
+func (ro readOnlyFS) Rename(oldname, newname string) error {
+	return ro.readOnly("rename", oldname)
+}
+
+func (ro readOnlyFS) Remove(name string) error {
+	return ro.readOnly("remove", name)
+}
@@ -1,11 +1,14 @@
 //spellchecker:words content
 package content
 
-//spellchecker:words html template http pkglib httpx minify recovery
+//spellchecker:words bytes html template http sync pkglib httpx minify recovery
 import (
+	"bytes"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
+	"sync"
 
 	"go.tkw01536.de/pkglib/httpx"
 	"go.tkw01536.de/pkglib/minify"
@@ -24,35 +27,141 @@ func WriteHTML[C any](context C, e error, template *template.Template, w http.Re
 	return WriteHTMLI(context, e, template, httpx.HTMLInterceptor, w, r)
 }
 
+// DefaultHTMLBufferSize is the default maximum number of bytes of output buffered when writing html, see [HTMLOptions.BufferSize].
+const DefaultHTMLBufferSize = 4 << 20
+
+// HTMLOptions determine how [WriteHTMLWith] writes html responses.
+type HTMLOptions struct {
+	// Interceptor intercepts errors passed to WriteHTMLWith, as well as errors executing the template.
+	Interceptor httpx.ErrInterceptor
+
+	// BufferSize is the maximum number of bytes of output buffered.
+	//
+	// Output of a template is rendered into a buffer before it is minified and sent to the client.
+	// When rendering fails, the error is instead passed to the interceptor, and no partial output is sent.
+	// Once the output exceeds the buffer size, it is streamed to the client instead.
+	// A template error occurring after this point can no longer change the response, which is sent truncated.
+	//
+	// A zero value indicates [DefaultHTMLBufferSize], a negative value disables buffering entirely.
+	BufferSize int
+}
+
 // WriteHTMLI is like [WriteHTML], but uses a custom error interceptor.
-func WriteHTMLI[C any](context C, e error, template *template.Template, interceptor httpx.ErrInterceptor, w http.ResponseWriter, r *http.Request) (err error) {
+// It is equivalent to [WriteHTMLWith] with only the interceptor set.
+func WriteHTMLI[C any](context C, e error, template *template.Template, interceptor httpx.ErrInterceptor, w http.ResponseWriter, r *http.Request) error {
+	return WriteHTMLWith(context, e, template, HTMLOptions{Interceptor: interceptor}, w, r)
+}
+
+// WriteHTMLWith is like [WriteHTML], but is configured using opts.
+//
+// If executing the template fails before any output has been sent (see [HTMLOptions.BufferSize]),
+// the error is intercepted using opts.Interceptor as well.
+// The returned error is the error that occurred while executing the template, if any.
+func WriteHTMLWith[C any](context C, e error, template *template.Template, opts HTMLOptions, w http.ResponseWriter, r *http.Request) (err error) {
 	// intercept any errors
-	if interceptor.Intercept(w, r, e) {
+	if opts.Interceptor.Intercept(w, r, e) {
 		return nil
 	}
 
-	// write out the response as html
-	w.Header().Set("Content-Type", httpx.ContentTypeHTML)
+	limit := opts.BufferSize
+	if limit == 0 {
+		limit = DefaultHTMLBufferSize
+	}
+
+	buffer := htmlBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		// don't keep large buffers around
+		if buffer.Cap() > maxPooledHTMLBuffer {
+			return
+		}
+		buffer.Reset()
+		htmlBufferPool.Put(buffer)
+	}()
 
-	// minify html!
-	minifier := minify.Minify(httpx.ContentTypeHTML, w)
+	writer := &htmlWriter{w: w, buffer: buffer, limit: limit}
 	defer func() {
-		errClose := minifier.Close()
+		errClose := writer.Close()
 		if err == nil {
 			err = errClose
 		}
 	}()
 
-	// return it to the client
-	// and if there is an error, write it to the client
-	{
-		err := template.Execute(minifier, context)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			err = fmt.Errorf("failed to execute template: %w", err)
+	// render the template, and if there is an error, write it to the client
+	if err := template.Execute(writer, context); err != nil {
+		err = fmt.Errorf("failed to execute template: %w", err)
+		if writer.minifier == nil {
+			writer.discard()
+			opts.Interceptor.Intercept(w, r, err)
 		}
 		return err
 	}
+	return nil
+}
+
+// maxPooledHTMLBuffer is the maximum capacity of a buffer returned to htmlBufferPool.
+const maxPooledHTMLBuffer = 64 << 10
+
+// htmlBufferPool holds *bytes.Buffers used by [WriteHTMLWith].
+var htmlBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// htmlWriter buffers html output up to limit bytes, and then minifies it into w.
+type htmlWriter struct {
+	w      http.ResponseWriter
+	buffer *bytes.Buffer
+	limit  int
+
+	discarded bool           // buffered output was discarded
+	minifier  io.WriteCloser // minifier writing to w, non-nil once output is sent
+}
+
+func (hw *htmlWriter) Write(p []byte) (int, error) {
+	if hw.minifier == nil && hw.buffer.Len()+len(p) <= hw.limit {
+		return hw.buffer.Write(p)
+	}
+	if err := hw.send(); err != nil {
+		return 0, err
+	}
+	return hw.minifier.Write(p)
+}
+
+// send starts sending output to the client, beginning with any buffered output.
+func (hw *htmlWriter) send() error {
+	if hw.minifier != nil {
+		return nil
+	}
+
+	hw.w.Header().Set("Content-Type", httpx.ContentTypeHTML)
+	hw.w.WriteHeader(http.StatusOK)
+	hw.minifier = minify.Minify(httpx.ContentTypeHTML, hw.w)
+	if _, err := hw.minifier.Write(hw.buffer.Bytes()); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	hw.buffer.Reset()
+	return nil
+}
+
+// discard discards any buffered output, which will not be sent to the client.
+func (hw *htmlWriter) discard() {
+	hw.discarded = true
+	hw.buffer.Reset()
+}
+
+// Close sends any buffered output that has not been discarded, and finishes sending the output.
+func (hw *htmlWriter) Close() error {
+	if hw.discarded {
+		return nil
+	}
+	if err := hw.send(); err != nil {
+		return err
+	}
+	if err := hw.minifier.Close(); err != nil {
+		return fmt.Errorf("failed to close minifier: %w", err)
+	}
+	return nil
 }
 
 // C is the type of the context to be passed to the Template.
@@ -61,16 +170,37 @@ type HTMLHandler[C any] struct {
 
 	Template *template.Template // Template is the template to be rendered into responses
 
-	Interceptor             httpx.ErrInterceptor
+	// Templates and TemplateName refer to the template to be rendered by name.
+	// If Templates is not nil, the template is looked up on every request and used instead of Template.
+	// See [Templates.Lookup].
+	Templates    *Templates
+	TemplateName string
+
+	Interceptor httpx.ErrInterceptor
+
+	// LogTemplateExecuteError, when not nil, is called with errors looking up or executing the template.
 	LogTemplateExecuteError httpx.ErrorLogger
+
+	// BufferSize is the maximum number of bytes of output buffered, see [HTMLOptions.BufferSize].
+	BufferSize int
 }
 
 // ServeHTTP calls the handler, and then passes it and the template to WriteHTML.
 func (h HTMLHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// call the function
 	result, err := recovery.Safe(func() (T, error) { return h.Handler(r) })
+
+	// find the template to render, logging errors like errors executing it
+	template := h.Template
+	if err == nil && h.Templates != nil {
+		template, err = h.Templates.Lookup(h.TemplateName)
+		if err != nil && h.LogTemplateExecuteError != nil {
+			h.LogTemplateExecuteError(r, err)
+		}
+	}
+
 	{
-		err := WriteHTMLI(result, err, h.Template, h.Interceptor, w, r)
+		err := WriteHTMLWith(result, err, template, HTMLOptions{Interceptor: h.Interceptor, BufferSize: h.BufferSize}, w, r)
 		if err != nil && h.LogTemplateExecuteError != nil {
 			h.LogTemplateExecuteError(r, err)
 		}
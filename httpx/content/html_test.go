@@ -1,7 +1,7 @@
 //spellchecker:words content
 package content_test
 
-//spellchecker:words context html template http httptest testing pkglib httpx content
+//spellchecker:words context html template http httptest strings testing pkglib httpx content
 import (
 	"context"
 	"fmt"
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"go.tkw01536.de/pkglib/httpx"
@@ -48,7 +49,7 @@ func ExampleHTML() {
 	// /slice returned code 200 with location header "" and body "<!doctype html>Result: [hello 42]"
 	// /notfound returned code 404 with location header "" and body "<!doctype html><title>Not Found</title>Not Found"
 	// /other returned code 500 with location header "" and body "<!doctype html><title>Internal Server Error</title>Internal Server Error"
-	// /template_error returned code 500 with location header "" and body "<!doctype html>Result:"
+	// /template_error returned code 500 with location header "" and body "<!doctype html><title>Internal Server Error</title>Internal Server Error"
 }
 
 func makeRequest(handler http.Handler, path string) string {
@@ -105,3 +106,41 @@ func TestHTML_LogJSONEncodeError(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteHTMLWith_streaming(t *testing.T) {
+	t.Parallel()
+
+	// a template producing more output than can be buffered, and then failing
+	tpl := template.Must(template.New("large").Parse(`<!DOCTYPE html>{{ range .Items }}<p>{{ $.Line }}</p>{{ end }}{{ .Missing }}`))
+	data := struct {
+		Items []struct{}
+		Line  string
+	}{
+		Items: make([]struct{}, 10),
+		Line:  strings.Repeat("x", 1000),
+	}
+
+	for _, size := range []int{1000, -1} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			opts := content.HTMLOptions{Interceptor: httpx.HTMLInterceptor, BufferSize: size}
+			err := content.WriteHTMLWith(data, nil, tpl, opts, rr, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+			if err == nil {
+				t.Fatal("WriteHTMLWith() did not return an error")
+			}
+
+			// the output was already sent, so the status could not be changed
+			if rr.Code != http.StatusOK {
+				t.Errorf("WriteHTMLWith() sent status %d, want %d", rr.Code, http.StatusOK)
+			}
+			if got := rr.Header().Get("Content-Type"); got != httpx.ContentTypeHTML {
+				t.Errorf("WriteHTMLWith() sent content type %q, want %q", got, httpx.ContentTypeHTML)
+			}
+			if got := rr.Body.Len(); got < len(data.Items)*len(data.Line) {
+				t.Errorf("WriteHTMLWith() sent %d bytes, want at least %d", got, len(data.Items)*len(data.Line))
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+//spellchecker:words content
+package content
+
+//spellchecker:words html template slices sync time
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TemplateOptions configure how [Templates] are loaded.
+type TemplateOptions struct {
+	// Layout is the path of the base layout used by all pages.
+	// When set, executing a page executes the layout instead.
+	// Pages typically customize it by defining blocks declared in the layout.
+	//
+	// When empty, pages are executed directly.
+	Layout string
+
+	// Partials are glob patterns, see [fs.Glob], of files parsed along with every page.
+	// Each partial is available under its path, and may define additional templates.
+	Partials []string
+
+	// Funcs are functions available to all templates.
+	Funcs template.FuncMap
+
+	// Dev enables development mode.
+	// In development mode, a page is reparsed whenever any of its files has changed since it was last parsed.
+	// This should only be used when loading templates from disk.
+	Dev bool
+}
+
+// Templates is a registry of html templates loaded from a filesystem.
+// Templates are referred to by the path of their page within the filesystem, such as "pages/index.html".
+//
+// A page is parsed along with the layout and partials, see [TemplateOptions].
+// Files are parsed in order layout, partials, page; later definitions of a template replace earlier ones.
+// This allows a page to override blocks of the layout or partials.
+//
+// Templates is safe for concurrent use.
+type Templates struct {
+	fsys fs.FS
+	opts TemplateOptions
+
+	m     sync.Mutex
+	pages map[string]*page
+}
+
+// page is a parsed page of a template registry.
+type page struct {
+	template *template.Template
+	partials []string             // paths matched by partials
+	stamps   map[string]fileStamp // stamps of all files used, only populated in development mode
+}
+
+// fileStamp is used to detect changes to a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewTemplates creates a new registry of templates loaded from fsys.
+// Templates are parsed lazily on first use.
+func NewTemplates(fsys fs.FS, opts TemplateOptions) *Templates {
+	return &Templates{fsys: fsys, opts: opts, pages: make(map[string]*page)}
+}
+
+// Lookup returns the template for the page with the given name, parsing it if needed.
+// Outside of development mode, pages are parsed at most once.
+func (templates *Templates) Lookup(name string) (*template.Template, error) {
+	templates.m.Lock()
+	defer templates.m.Unlock()
+
+	if cached, ok := templates.pages[name]; ok && !templates.changed(cached) {
+		return cached.template, nil
+	}
+
+	parsed, err := templates.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	templates.pages[name] = parsed
+	return parsed.template, nil
+}
+
+// Must is like [Templates.Lookup], but panics if the page cannot be parsed.
+// It is intended to check pages during program initialization.
+func (templates *Templates) Must(name string) *template.Template {
+	return template.Must(templates.Lookup(name))
+}
+
+// partials returns the paths of all partials.
+func (templates *Templates) partials() ([]string, error) {
+	var paths []string
+	for _, pattern := range templates.opts.Partials {
+		matches, err := fs.Glob(templates.fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find partials: %w", err)
+		}
+		for _, match := range matches {
+			if !slices.Contains(paths, match) {
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// parse parses the page with the given name.
+func (templates *Templates) parse(name string) (*page, error) {
+	partials, err := templates.partials()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(partials)+2)
+	if templates.opts.Layout != "" {
+		files = append(files, templates.opts.Layout)
+	}
+	files = append(files, partials...)
+	files = append(files, name)
+
+	parsed := &page{partials: partials}
+	if templates.opts.Dev {
+		parsed.stamps = make(map[string]fileStamp, len(files))
+	}
+
+	root := template.New(name).Funcs(templates.opts.Funcs)
+	for _, file := range files {
+		if parsed.stamps != nil {
+			stamp, err := templates.stamp(file)
+			if err != nil {
+				return nil, err
+			}
+			parsed.stamps[file] = stamp
+		}
+
+		data, err := fs.ReadFile(templates.fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %q: %w", file, err)
+		}
+
+		tpl := root
+		if file != name {
+			tpl = root.New(file)
+		}
+		if _, err := tpl.Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("failed to parse template %q: %w", file, err)
+		}
+	}
+
+	parsed.template = root
+	if templates.opts.Layout != "" {
+		parsed.template = root.Lookup(templates.opts.Layout)
+	}
+	return parsed, nil
+}
+
+// stamp returns the stamp of the given file.
+func (templates *Templates) stamp(file string) (fileStamp, error) {
+	info, err := fs.Stat(templates.fsys, file)
+	if err != nil {
+		return fileStamp{}, fmt.Errorf("failed to stat template %q: %w", file, err)
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// changed checks if any of the files of a page have changed since it was parsed.
+// Outside of development mode, it always returns false.
+func (templates *Templates) changed(cached *page) bool {
+	if !templates.opts.Dev {
+		return false
+	}
+
+	partials, err := templates.partials()
+	if err != nil || !slices.Equal(partials, cached.partials) {
+		return true
+	}
+	for file, stamp := range cached.stamps {
+		if current, err := templates.stamp(file); err != nil || !current.modTime.Equal(stamp.modTime) || current.size != stamp.size {
+			return true
+		}
+	}
+	return false
+}
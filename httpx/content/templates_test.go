@@ -0,0 +1,152 @@
+//spellchecker:words content
+package content_test
+
+//spellchecker:words html template http httptest strings testing fstest time pkglib httpx content
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.tkw01536.de/pkglib/httpx"
+	"go.tkw01536.de/pkglib/httpx/content"
+)
+
+// templateFS returns a filesystem with a layout, partials and pages.
+func templateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layout.html":         {Data: []byte(`<!DOCTYPE html><title>{{ block "title" . }}Default{{ end }}</title>{{ template "partials/nav.html" . }}{{ block "main" . }}{{ end }}`)},
+		"partials/nav.html":   {Data: []byte(`<nav>{{ shout "home" }}</nav>`)},
+		"partials/footer.txt": {Data: []byte(`not a partial`)},
+		"pages/index.html":    {Data: []byte(`{{ define "main" }}Hello {{ .Name }}{{ end }}`)},
+		"pages/about.html":    {Data: []byte(`{{ define "title" }}About{{ end }}{{ define "main" }}About us{{ end }}`)},
+		"pages/broken.html":   {Data: []byte(`{{ define "main" }}{{ .Missing }`)},
+	}
+}
+
+var templateOptions = content.TemplateOptions{
+	Layout:   "layout.html",
+	Partials: []string{"partials/*.html"},
+	Funcs:    template.FuncMap{"shout": strings.ToUpper},
+}
+
+func ExampleTemplates() {
+	templates := content.NewTemplates(templateFS(), templateOptions)
+
+	handler := content.HTMLHandler[map[string]string]{
+		Handler: func(r *http.Request) (map[string]string, error) {
+			return map[string]string{"Name": "World"}, nil
+		},
+		Templates:    templates,
+		TemplateName: "pages/index.html",
+		Interceptor:  httpx.HTMLInterceptor,
+	}
+	fmt.Println(makeRequest(handler, "/"))
+
+	handler.TemplateName = "pages/missing.html"
+	fmt.Println(makeRequest(handler, "/"))
+
+	// Output: / returned code 200 with location header "" and body "<!doctype html><title>Default</title><nav>HOME</nav>Hello World"
+	// / returned code 500 with location header "" and body "<!doctype html><title>Internal Server Error</title>Internal Server Error"
+}
+
+func TestHTMLHandler_lookupError(t *testing.T) {
+	t.Parallel()
+
+	var logged error
+	handler := content.HTMLHandler[any]{
+		Handler:                 func(r *http.Request) (any, error) { return nil, nil },
+		Templates:               content.NewTemplates(templateFS(), templateOptions),
+		TemplateName:            "pages/broken.html",
+		Interceptor:             httpx.TextInterceptor,
+		LogTemplateExecuteError: func(r *http.Request, err error) { logged = err },
+	}
+
+	got := makeRequest(handler, "/")
+	if want := `/ returned code 500 with location header "" and body "Internal Server Error"`; got != want {
+		t.Errorf("ServeHTTP() = %s, want %s", got, want)
+	}
+	if logged == nil {
+		t.Error("LogTemplateExecuteError was not called for a template that could not be looked up")
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	t.Parallel()
+
+	templates := content.NewTemplates(templateFS(), templateOptions)
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"pages/index.html", `<!DOCTYPE html><title>Default</title><nav>HOME</nav>Hello you`, false},
+		{"pages/about.html", `<!DOCTYPE html><title>About</title><nav>HOME</nav>About us`, false},
+		{"pages/broken.html", "", true},
+		{"pages/missing.html", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tpl, err := templates.Lookup(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lookup() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var builder strings.Builder
+			if err := tpl.Execute(&builder, map[string]string{"Name": "you"}); err != nil {
+				t.Fatal(err)
+			}
+			if got := builder.String(); got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplates_dev(t *testing.T) {
+	t.Parallel()
+
+	for _, dev := range []bool{false, true} {
+		t.Run(fmt.Sprint(dev), func(t *testing.T) {
+			t.Parallel()
+
+			fsys := templateFS()
+			opts := templateOptions
+			opts.Dev = dev
+			templates := content.NewTemplates(fsys, opts)
+
+			render := func() string {
+				rr := httptest.NewRecorder()
+				tpl := templates.Must("pages/about.html")
+				if err := tpl.Execute(rr, nil); err != nil {
+					t.Fatal(err)
+				}
+				return rr.Body.String()
+			}
+
+			before := render()
+
+			// change a partial, and add a new one
+			fsys["partials/nav.html"] = &fstest.MapFile{Data: []byte(`<nav>changed</nav>`), ModTime: time.Now()}
+			fsys["partials/new.html"] = &fstest.MapFile{Data: []byte(`{{ define "title" }}New{{ end }}`)}
+
+			got, want := render(), before
+			if dev {
+				want = `<!DOCTYPE html><title>About</title><nav>changed</nav>About us`
+			}
+			if got != want {
+				t.Errorf("render() = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+//spellchecker:words httpx
+package httpx
+
+//spellchecker:words bytes compress gzip zlib crypto sha256 encoding base64 http strings sync pkglib errorsx lazy
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/lazy"
+)
+
+//spellchecker:words brotli
+
+// Encoding is a content coding that can be applied to the body of a [Response].
+type Encoding struct {
+	// Name is the token identifying the encoding in the Accept-Encoding and Content-Encoding headers, such as "gzip".
+	Name string
+
+	// NewWriter returns a writer that encodes data written to it into dst.
+	// The returned writer is closed once all data has been written.
+	NewWriter func(dst io.Writer) (io.WriteCloser, error)
+}
+
+// DefaultEncodings are the encodings used by a [Response] that does not specify any.
+// They are listed in order of preference.
+//
+// Further encodings, such as brotli, can be supported by prepending them during program initialization.
+var DefaultEncodings = []Encoding{
+	{
+		Name: "gzip",
+		NewWriter: func(dst io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(dst, gzip.BestCompression)
+		},
+	},
+	{
+		Name: "deflate",
+		NewWriter: func(dst io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(dst, zlib.BestCompression)
+		},
+	},
+}
+
+// encodeInto encodes body into buf using the given encoding.
+func encodeInto(encoding Encoding, buf *bytes.Buffer, body []byte) (e error) {
+	writer, err := encoding.NewWriter(buf)
+	if err != nil {
+		return fmt.Errorf("failed to create %s writer: %w", encoding.Name, err)
+	}
+	defer errorsx.Close(writer, &e, "writer")
+
+	if _, err := writer.Write(body); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+	return nil
+}
+
+// negotiateEncoding determines which of the given encodings best matches the Accept-Encoding header of r.
+// Encodings are assigned the quality of the matching token, or of the "*" token.
+// The encoding with the highest quality is returned; ties are broken by the order of encodings.
+//
+// If r has no Accept-Encoding header, or no encoding is acceptable, returns -1 to indicate the identity encoding.
+func negotiateEncoding(r *http.Request, encodings []Encoding) int {
+	qualities := make(map[string]float64)
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for element := range strings.SplitSeq(value, ",") {
+			token, params, _ := strings.Cut(element, ";")
+			token = strings.ToLower(strings.TrimSpace(token))
+			if token == "" {
+				continue
+			}
+			if q, valid := parseQuality(params); valid {
+				qualities[token] = q
+			}
+		}
+	}
+
+	index, best := -1, 0.0
+	for i, encoding := range encodings {
+		q, ok := qualities[strings.ToLower(encoding.Name)]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > best {
+			index, best = i, q
+		}
+	}
+	return index
+}
+
+// responseCache caches values computed from the body of a response.
+// See [Response.Cache].
+type responseCache struct {
+	body []byte // body the cached values were computed from
+
+	etag lazy.Lazy[string]
+
+	m       sync.Mutex                    // protects encoded
+	encoded map[string]*lazy.Lazy[[]byte] // encoded bodies by name of encoding, nil when encoding is not beneficial
+}
+
+// valid checks if the cache was created for the given body.
+func (cache *responseCache) valid(body []byte) bool {
+	if cache == nil || len(cache.body) != len(body) {
+		return false
+	}
+	return len(body) == 0 || &cache.body[0] == &body[0]
+}
+
+// Cache returns a copy of the response that caches values derived from its body.
+// These are the ETag and the encoded variants of the body.
+// Each is computed lazily, at most once, when first needed.
+//
+// ETags are only sent and bodies are only encoded for responses returned from Cache or [Response.Minify].
+// Assigning a new body to the returned response discards cached values.
+func (response Response) Cache() Response {
+	response.cache = &responseCache{body: response.Body}
+	return response
+}
+
+// etag returns the strong entity tag of the body of this response.
+// If the response does not cache derived values, returns the empty string.
+func (response Response) etag() string {
+	if !response.cache.valid(response.Body) {
+		return ""
+	}
+	return response.cache.etag.Get(func() string {
+		sum := sha256.Sum256(response.Body)
+		return `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+	})
+}
+
+// encoded returns the body of this response encoded with the given encoding.
+// If encoding is not beneficial, or the response does not cache encoded bodies, returns nil.
+func (response Response) encoded(encoding Encoding) []byte {
+	if !response.cache.valid(response.Body) || len(response.Body) == 0 {
+		return nil
+	}
+
+	cache := response.cache
+	cache.m.Lock()
+	if cache.encoded == nil {
+		cache.encoded = make(map[string]*lazy.Lazy[[]byte])
+	}
+	encoded, ok := cache.encoded[encoding.Name]
+	if !ok {
+		encoded = new(lazy.Lazy[[]byte])
+		cache.encoded[encoding.Name] = encoded
+	}
+	cache.m.Unlock()
+
+	return encoded.Get(func() []byte {
+		var buffer bytes.Buffer
+		if err := encodeInto(encoding, &buffer, response.Body); err != nil || buffer.Len() >= len(response.Body) {
+			return nil
+		}
+		return buffer.Bytes()
+	})
+}
+
+// representation determines the representation of the body to send in response to r.
+// It sets the Content-Encoding, ETag and Vary headers as appropriate, and returns the body to send.
+func (response Response) representation(w http.ResponseWriter, r *http.Request) []byte {
+	header := w.Header()
+
+	encodings := response.Encodings
+	if encodings == nil {
+		encodings = DefaultEncodings
+	}
+	if len(encodings) > 0 && response.cache.valid(response.Body) {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	etag := response.etag()
+	if index := negotiateEncoding(r, encodings); index >= 0 {
+		if body := response.encoded(encodings[index]); body != nil {
+			name := encodings[index].Name
+			header.Set("Content-Encoding", name)
+			header.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+name+`"`)
+			return body
+		}
+	}
+
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	return response.Body
+}
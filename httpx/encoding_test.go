@@ -0,0 +1,118 @@
+//spellchecker:words httpx
+package httpx_test
+
+//spellchecker:words compress gzip zlib http httptest strings testing pkglib httpx
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/httpx"
+)
+
+func TestResponse_encoding(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(strings.Repeat("hello world ", 100))
+	cached := httpx.Response{Body: body, CacheControl: "public, max-age=3600"}.Cache()
+
+	tests := []struct {
+		name           string
+		response       httpx.Response
+		acceptEncoding string
+		wantEncoding   string
+		wantETag       bool
+	}{
+		{"no header", cached, "", "", true},
+		{"gzip", cached, "gzip, deflate", "gzip", true},
+		{"deflate", cached, "gzip;q=0.5, deflate", "deflate", true},
+		{"wildcard", cached, "*", "gzip", true},
+		{"excluded", cached, "gzip;q=0, *", "deflate", true},
+		{"unsupported", cached, "br", "", true},
+		{"not cached", httpx.Response{Body: body}, "gzip", "", false},
+		{"not beneficial", httpx.Response{Body: []byte("short")}.Cache(), "gzip", "", true},
+		{"disabled", httpx.Response{Body: body, Encodings: []httpx.Encoding{}}.Cache(), "gzip", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			tt.response.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("ServeHTTP() used encoding %q, want %q", got, tt.wantEncoding)
+			}
+
+			var reader io.Reader = rr.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader = zr
+			case "deflate":
+				zr, err := zlib.NewReader(reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader = zr
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(tt.response.Body) {
+				t.Errorf("ServeHTTP() sent body %q, want %q", got, tt.response.Body)
+			}
+
+			etag := rr.Header().Get("ETag")
+			if !tt.wantETag {
+				if etag != "" {
+					t.Errorf("ServeHTTP() sent ETag %q, want none", etag)
+				}
+				return
+			}
+			if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, tt.wantEncoding+`"`) {
+				t.Errorf("ServeHTTP() sent ETag %q", etag)
+			}
+
+			// a conditional request for the same representation is not modified
+			req.Header.Set("If-None-Match", etag)
+			rr = httptest.NewRecorder()
+			tt.response.ServeHTTP(rr, req)
+			if rr.Code != http.StatusNotModified {
+				t.Errorf("ServeHTTP() with If-None-Match sent status %d, want %d", rr.Code, http.StatusNotModified)
+			}
+		})
+	}
+
+	// different representations have different entity tags
+	etags := make(map[string]struct{})
+	for _, encoding := range []string{"", "gzip", "deflate"} {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		cached.ServeHTTP(rr, req)
+
+		etags[rr.Header().Get("ETag")] = struct{}{}
+		if got := rr.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+			t.Errorf("ServeHTTP() sent Cache-Control %q", got)
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("ServeHTTP() sent Vary %q", got)
+		}
+	}
+	if len(etags) != 3 {
+		t.Errorf("ServeHTTP() sent %d distinct ETags, want 3", len(etags))
+	}
+}
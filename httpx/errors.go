@@ -1,10 +1,12 @@
 //spellchecker:words httpx
 package httpx
 
-//spellchecker:words http
+//spellchecker:words http pkglib errorsx
 import (
 	"fmt"
 	"net/http"
+
+	"go.tkw01536.de/pkglib/errorsx"
 )
 
 //spellchecker:words nolint errname
@@ -56,4 +58,27 @@ const (
 	ErrNotFound            = StatusCode(http.StatusNotFound)
 	ErrForbidden           = StatusCode(http.StatusForbidden)
 	ErrMethodNotAllowed    = StatusCode(http.StatusMethodNotAllowed)
+	ErrUnauthorized        = StatusCode(http.StatusUnauthorized)
+	ErrConflict            = StatusCode(http.StatusConflict)
+	ErrServiceUnavailable  = StatusCode(http.StatusServiceUnavailable)
 )
+
+// kindStatuses maps error kinds to their http status codes.
+var kindStatuses = map[errorsx.Kind]StatusCode{
+	errorsx.KindNotFound:     ErrNotFound,
+	errorsx.KindInvalidInput: ErrBadRequest,
+	errorsx.KindConflict:     ErrConflict,
+	errorsx.KindUnauthorized: ErrUnauthorized,
+	errorsx.KindUnavailable:  ErrServiceUnavailable,
+	errorsx.KindInternal:     ErrInternalServerError,
+}
+
+// KindStatus returns the http status code corresponding to the given kind of error.
+// If there is no such status code, returns [ErrInternalServerError] and false.
+func KindStatus(kind errorsx.Kind) (StatusCode, bool) {
+	code, ok := kindStatuses[kind]
+	if !ok {
+		return ErrInternalServerError, false
+	}
+	return code, true
+}
@@ -1,18 +1,19 @@
 //spellchecker:words httpx
 package httpx
 
-//spellchecker:words html template http runtime debug strings embed
+//spellchecker:words html template http runtime debug strings embed pkglib errorsx
 import (
-	"fmt"
 	"html/template"
 	"net/http"
 	"runtime/debug"
 	"strings"
 
 	_ "embed"
+
+	"go.tkw01536.de/pkglib/errorsx"
 )
 
-//spellchecker:words errpage nolint errorlint
+//spellchecker:words errpage
 
 // RenderErrorPage renders a debug error page instead of the fallback response res.
 // The error page is intended to replace error pages for debugging and should not be used in production.
@@ -27,7 +28,7 @@ func RenderErrorPage(err error, res Response, w http.ResponseWriter, r *http.Req
 func newErrorPage(err error, r *http.Request) (page errorPage) {
 	page.Stack = string(debug.Stack())
 
-	page.Error = newSError(err)
+	page.Error = errorsx.NewTree(err)
 
 	if r != nil {
 		page.Method = r.Method
@@ -50,8 +51,8 @@ type errorPage struct {
 	// Stack is the stack as returned by [runtime/debug.Stack]
 	Stack string
 
-	// Error is the underlying error cause
-	Error sError
+	// Error is the tree of the underlying error cause
+	Error errorsx.Tree
 }
 
 // Response replaces the body and content type of the given response by the formatted html.
@@ -85,43 +86,4 @@ func (err errorPage) FormatHTML(res Response) template.HTML {
 	return template.HTML(builder.String()) // #nosec G203 -- template not attacker controlled
 }
 
-// sError represents a stringified error.
-type sError struct {
-	Error  string // Error is the result of calling the Error() method
-	Type   string // Type is the type of error
-	Source string // Source is the result of formatting the error as a go source
-
-	Unwrap []sError // The source of wrapped errors
-}
-
-// newSError safely turns an error into an error.
-func newSError(err error) sError {
-	e := sError{
-		Error:  fmt.Sprintf("%s", err),
-		Type:   fmt.Sprintf("%T", err),
-		Source: fmt.Sprintf("%#v", err),
-	}
-
-	// find the child errors
-	var children []error
-	switch x := err.(type) { //nolint:errorlint // want to unwrap directly
-	case interface{ Unwrap() error }:
-		children = []error{x.Unwrap()}
-	case interface{ Unwrap() []error }:
-		children = x.Unwrap()
-	}
-
-	// turn non-nil errors into NewError objects
-	e.Unwrap = make([]sError, 0, len(children))
-	for _, err := range children {
-		if err == nil {
-			continue
-		}
-		e.Unwrap = append(e.Unwrap, newSError(err))
-	}
-
-	// and we've built the error
-	return e
-}
-
 //spellchecker:words nosec
@@ -45,7 +45,13 @@ type Form[Data any] struct {
 	// It is passed the return value of [TemplateContext].
 	Template *template.Template
 
-	// LogTemplateError is intended to log a non-nil error being returned from executing the template.
+	// Templates and TemplateName refer to the template to render for GET requests by name.
+	// If Templates is not nil, the template is looked up on every request and used instead of Template.
+	// See [content.Templates.Lookup].
+	Templates    *content.Templates
+	TemplateName string
+
+	// LogTemplateError is intended to log a non-nil error being returned from looking up or executing the template.
 	// If it is nil, no logging occurs.
 	LogTemplateError httpx.ErrorLogger
 
@@ -171,7 +177,14 @@ func (form *Form[Data]) renderForm(err error, afterSuccess bool, values map[stri
 	ctx := FormContext{Err: err, Form: template, AfterSuccess: afterSuccess}
 
 	// must have a form or a RenderForm
-	if form.Template == nil {
+	tpl := form.Template
+	var tplErr error
+	if form.Templates != nil {
+		tpl, tplErr = form.Templates.Lookup(form.TemplateName)
+		if tplErr != nil && form.LogTemplateError != nil {
+			form.LogTemplateError(r, tplErr)
+		}
+	} else if tpl == nil {
 		panic("form.Template is nil")
 	}
 
@@ -185,7 +198,7 @@ func (form *Form[Data]) renderForm(err error, afterSuccess bool, values map[stri
 
 	// write out the html and log an error (if any)
 	{
-		err := content.WriteHTML(tplContext, nil, form.Template, w, r)
+		err := content.WriteHTML(tplContext, tplErr, tpl, w, r)
 		if err != nil && form.LogTemplateError != nil {
 			form.LogTemplateError(r, err)
 		}
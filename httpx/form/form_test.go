@@ -1,7 +1,7 @@
 //spellchecker:words form
 package form_test
 
-//spellchecker:words errors html template http strconv testing pkglib httpx form field
+//spellchecker:words errors html template http strconv testing fstest pkglib httpx content form field
 import (
 	"errors"
 	"fmt"
@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"strconv"
 	"testing"
+	"testing/fstest"
 
+	"go.tkw01536.de/pkglib/httpx/content"
 	"go.tkw01536.de/pkglib/httpx/form"
 	"go.tkw01536.de/pkglib/httpx/form/field"
 )
@@ -48,6 +50,25 @@ func TestForm_logger(t *testing.T) {
 	}
 }
 
+func TestForm_loggerLookup(t *testing.T) {
+	t.Parallel()
+
+	frm := makeTestForm(t)
+	frm.Templates = content.NewTemplates(fstest.MapFS{}, content.TemplateOptions{})
+	frm.TemplateName = "missing.html"
+
+	var logged error
+	frm.LogTemplateError = func(r *http.Request, err error) {
+		logged = err
+	}
+
+	makeFormRequest(t, &frm, nil)
+
+	if logged == nil {
+		t.Error("LogTemplateError was not called for a template that could not be looked up")
+	}
+}
+
 func TestForm_formContext_afterSuccess(t *testing.T) {
 	t.Parallel()
 
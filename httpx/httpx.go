@@ -22,6 +22,14 @@ type Response struct {
 
 	Modtime    time.Time
 	StatusCode int // defaults to a 2XX status code
+
+	CacheControl string // value of the "Cache-Control" header, omitted when empty
+
+	// Encodings are the encodings the body may be sent with, see [Response.Cache].
+	// A nil value uses [DefaultEncodings], an empty non-nil value disables encoding.
+	Encodings []Encoding
+
+	cache *responseCache // see [Response.Cache]
 }
 
 // Content Types for standard content offered by several functions.
@@ -32,9 +40,10 @@ const (
 )
 
 // Minify returns a copy of the response with minified content.
+// The returned response caches values derived from the minified content, see [Response.Cache].
 func (response Response) Minify() Response {
 	response.Body = minify.MinifyBytes(response.ContentType, response.Body)
-	return response
+	return response.Cache()
 }
 
 // Now returns a copy of the response with the Modtime field set to the current time in UTC.
@@ -43,17 +52,27 @@ func (response Response) Now() Response {
 	return response
 }
 
+// ServeHTTP implements [http.Handler].
+//
+// If the response caches derived values (see [Response.Cache]), it is sent with a strong ETag computed once from the body.
+// The body is then also sent using the encoding that best matches the Accept-Encoding header of the request,
+// when this makes it smaller.
 func (response Response) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// setup and send the ContentType header iff it is set
 	if response.ContentType == "" {
 		response.ContentType = ContentTypeText
 	}
 	w.Header().Set("Content-Type", response.ContentType)
+	if response.CacheControl != "" {
+		w.Header().Set("Cache-Control", response.CacheControl)
+	}
+
+	body := response.representation(w, r)
 
 	// when no status code is set use [http.ServeContent]
 	// which is way better than anything we could implement
 	if response.StatusCode == 0 {
-		http.ServeContent(w, r, "", response.Modtime, bytes.NewReader(response.Body))
+		http.ServeContent(w, r, "", response.Modtime, bytes.NewReader(body))
 		return
 	}
 
@@ -64,7 +83,7 @@ func (response Response) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// write only the response with the given content type
 	w.WriteHeader(response.StatusCode)
-	_, _ = w.Write(response.Body)
+	_, _ = w.Write(body)
 }
 
 // ErrorLogger is a function that can log an error occurred during some http handling process.
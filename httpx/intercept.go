@@ -1,11 +1,13 @@
 //spellchecker:words httpx
 package httpx
 
-//spellchecker:words encoding json errors http
+//spellchecker:words encoding json errors http pkglib errorsx
 import (
 	"encoding/json/v2"
 	"errors"
 	"net/http"
+
+	"go.tkw01536.de/pkglib/errorsx"
 )
 
 // ErrInterceptor can handle errors for http responses and render appropriate error responses.
@@ -15,6 +17,10 @@ type ErrInterceptor struct {
 	// Errors are compared using [errors.Is] is undetermined order.
 	// This means that if an error that [errors.Is] for multiple keys,
 	// the returned response may any of the values.
+	//
+	// If an error does not match any key, but has an [errorsx.Kind],
+	// the kind is mapped to a [StatusCode] using [KindStatus].
+	// If that StatusCode is a key of Errors, the corresponding response is used.
 	Errors map[error]Response
 
 	// Fallback is the response for errors that are not of any of the above error classes.
@@ -29,6 +35,34 @@ type ErrInterceptor struct {
 
 	// OnFallback is called when an unknown error is intercepted.
 	OnFallback func(*http.Request, error)
+
+	// Render, when not nil, writes the response for an intercepted error instead of serving the matched response.
+	// It receives the error along with the response matched as described above.
+	// It is not called when RenderError is set.
+	//
+	// See [RenderProblem] for an example.
+	Render func(w http.ResponseWriter, r *http.Request, err error, res Response)
+
+	// Offers holds alternative representations of errors for specific media types.
+	//
+	// When non-empty, the offer best matching the Accept header of the request is determined as by [Negotiate].
+	// The error is always classified using Errors and Fallback of this interceptor, and OnFallback and RenderError of this interceptor apply.
+	// The chosen offer only determines how the classified response is represented:
+	// If its interceptor has a Render function, it is called with the classified response.
+	// Otherwise, the response of its Errors for the classified status code is served.
+	//
+	// If no offer is acceptable, the Accept header does not express a preference (e.g. "*/*"),
+	// or the chosen interceptor has neither a Render function nor a response for the status code,
+	// the error is represented as if there were no offers.
+	// Fields of the chosen interceptor other than Errors and Render are ignored.
+	Offers []InterceptorOffer
+}
+
+// InterceptorOffer is an interceptor offered for a specific media type.
+// See [ErrInterceptor.Offers].
+type InterceptorOffer struct {
+	MediaType   string // media type, such as "text/html"
+	Interceptor ErrInterceptor
 }
 
 // Intercept intercepts the given error, and writes the response to the struct.
@@ -53,30 +87,61 @@ func (ei ErrInterceptor) Intercept(w http.ResponseWriter, r *http.Request, err e
 		return false
 	}
 
+	res, ok := ei.match(err)
+	if !ok && ei.OnFallback != nil {
+		ei.OnFallback(r, err)
+	}
+
 	if ei.RenderError {
-		ei.renderError(w, r, err)
+		RenderErrorPage(err, res, w, r)
+		return true
+	}
+
+	if offer, ok := ei.negotiate(w, r); ok && offer.represent(w, r, err, res) {
 		return true
 	}
 
-	ei.interceptError(w, r, err)
+	if ei.Render != nil {
+		ei.Render(w, r, err, res)
+		return true
+	}
+	res.ServeHTTP(w, r)
 	return true
 }
 
-func (ei ErrInterceptor) renderError(w http.ResponseWriter, r *http.Request, err error) {
-	res, ok := ei.match(err)
-	if !ok && ei.OnFallback != nil {
-		ei.OnFallback(r, err)
+// negotiate returns the interceptor of the offer to use for the given request, if any.
+func (ei ErrInterceptor) negotiate(w http.ResponseWriter, r *http.Request) (ErrInterceptor, bool) {
+	if len(ei.Offers) == 0 {
+		return ErrInterceptor{}, false
+	}
+	w.Header().Add("Vary", "Accept")
+
+	mediaTypes := make([]string, len(ei.Offers))
+	for i, offer := range ei.Offers {
+		mediaTypes[i] = offer.MediaType
 	}
 
-	RenderErrorPage(err, res, w, r)
+	index, specific := negotiate(r, mediaTypes)
+	if index < 0 || !specific {
+		return ErrInterceptor{}, false
+	}
+	return ei.Offers[index].Interceptor, true
 }
 
-func (ei ErrInterceptor) interceptError(w http.ResponseWriter, r *http.Request, err error) {
-	res, ok := ei.match(err)
-	if !ok && ei.OnFallback != nil {
-		ei.OnFallback(r, err)
+// represent writes the representation of the response res, which err was classified as.
+// It returns false without writing anything if ei has neither a Render function nor a response for the status code of res.
+func (ei ErrInterceptor) represent(w http.ResponseWriter, r *http.Request, err error, res Response) bool {
+	if ei.Render != nil {
+		ei.Render(w, r, err, res)
+		return true
 	}
-	res.ServeHTTP(w, r)
+
+	own, ok := ei.Errors[StatusCode(res.StatusCode)]
+	if !ok {
+		return false
+	}
+	own.ServeHTTP(w, r)
+	return true
 }
 
 func (ei ErrInterceptor) match(err error) (Response, bool) {
@@ -85,6 +150,14 @@ func (ei ErrInterceptor) match(err error) (Response, bool) {
 			return res, true
 		}
 	}
+
+	// check if we have a response for the kind of error
+	if code, ok := KindStatus(errorsx.KindOf(err)); ok {
+		if res, ok := ei.Errors[code]; ok {
+			return res, true
+		}
+	}
+
 	return ei.Fallback, false
 }
 
@@ -95,6 +168,9 @@ var statuses = []StatusCode{
 	ErrNotFound,
 	ErrForbidden,
 	ErrMethodNotAllowed,
+	ErrUnauthorized,
+	ErrConflict,
+	ErrServiceUnavailable,
 }
 
 // commonInterceptor creates a new ErrInterceptor handling default responses.
@@ -116,6 +192,20 @@ func commonInterceptor(contentType string, body func(code StatusCode) []byte) Er
 	return interceptor
 }
 
+// NegotiatingInterceptor returns an interceptor that classifies errors using def,
+// and represents them using [HTMLInterceptor], [JSONInterceptor] or [TextInterceptor] based on the Accept header of the request.
+// When the request does not prefer any of these, or they have no response for the status code, def is used.
+//
+// See [ErrInterceptor.Offers] for details.
+func NegotiatingInterceptor(def ErrInterceptor) ErrInterceptor {
+	def.Offers = []InterceptorOffer{
+		{MediaType: "text/html", Interceptor: HTMLInterceptor},
+		{MediaType: "application/json", Interceptor: JSONInterceptor},
+		{MediaType: "text/plain", Interceptor: TextInterceptor},
+	}
+	return def
+}
+
 // Common interceptors for specific content types.
 //
 // These handle all common http status codes by sending their response with a common error code.
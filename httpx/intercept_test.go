@@ -1,14 +1,16 @@
 //spellchecker:words httpx
 package httpx_test
 
-//spellchecker:words context http httptest pkglib httpx
+//spellchecker:words context errors http httptest pkglib errorsx httpx
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 
+	"go.tkw01536.de/pkglib/errorsx"
 	"go.tkw01536.de/pkglib/httpx"
 )
 
@@ -210,3 +212,37 @@ func ExampleHTMLInterceptor() {
 	// "/notfound" returned code 404 with text/html; charset=utf-8 "<!doctype html><title>Not Found</title>Not Found"
 	// "/forbidden" returned code 403 with text/html; charset=utf-8 "<!doctype html><title>Forbidden</title>Forbidden"
 }
+
+func ExampleErrInterceptor_kind() {
+	interceptor := httpx.TextInterceptor
+
+	// an error of a specific kind
+	errNoSuchUser := errorsx.WithKind(errors.New("no such user"), errorsx.KindNotFound) //nolint:err113 // example
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ... do some work ...
+		// in prod this would be an error returned from some operation
+		result := fmt.Errorf("failed to find user: %w", errNoSuchUser)
+
+		// intercept an error
+		if interceptor.Intercept(w, r, result) {
+			return
+		}
+
+		_, _ = w.Write([]byte("Normal response"))
+	})
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	rrr := rr.Result()
+	result, _ := io.ReadAll(rrr.Body)
+	fmt.Printf("returned code %d with %q\n", rrr.StatusCode, string(result))
+
+	// Output: returned code 404 with "Not Found"
+}
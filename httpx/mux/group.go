@@ -0,0 +1,157 @@
+package mux
+
+//spellchecker:words http path slices strings
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Middleware wraps an [http.Handler], for example using one of the functions in package wrap.
+type Middleware = func(http.Handler) http.Handler
+
+// Use adds middleware to this mux.
+//
+// Middleware applies to all requests served by this mux, regardless of when routes were registered.
+// This includes requests served by NotFound and MethodNotAllowed.
+// Middleware added first is outermost, that is it sees the request first.
+//
+// Handlers returned by [Mux.Match] are not wrapped by middleware added to the mux itself.
+func (mux *Mux) Use(middleware ...Middleware) {
+	mux.middleware = append(mux.middleware, middleware...)
+}
+
+// chain wraps h with the given middleware, the first one being outermost.
+func chain(h http.Handler, middleware []Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// Group registers a group of routes sharing a common path prefix.
+//
+// fn is called with a new, empty mux to register the routes of the group on.
+// Once fn returns, its routes are added to mux, with prefix prepended to their paths.
+// Middleware added to the group using [Mux.Use] only applies to the routes of the group,
+// and is applied inside any middleware of mux.
+// Names of routes in the group are added to mux; routes must be named before fn returns.
+// NotFound and MethodNotAllowed of the group are ignored.
+//
+// Unlike [Mux.Mount], handlers of the group see the full request path.
+// The prefix may contain "{name}" segments, which are then available to all routes of the group.
+// In that case, the group may only contain pattern routes.
+//
+// Group panics if the prefix is invalid, or a name of a route in the group is already in use.
+func (mux *Mux) Group(prefix string, fn func(group *Mux)) {
+	segments, err := parsePath(prefix)
+	if err == nil {
+		err = checkPrefix(segments)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Mux.Group: invalid prefix %q: %v", prefix, err))
+	}
+
+	var group Mux
+	fn(&group)
+
+	for _, route := range group.routes {
+		joined, err := newRoute(route.method, slices.Concat(segments, route.segments))
+		if err != nil {
+			panic(fmt.Sprintf("Mux.Group: invalid pattern %q: %v", route.pattern, err))
+		}
+		joined.handler = chain(route.handler, group.middleware)
+		joined.mount = route.mount
+		mux.insert(joined)
+
+		if route.name != "" {
+			joined.Name(route.name)
+		}
+	}
+
+	if len(group.exacts) == 0 && len(group.prefixes) == 0 {
+		return
+	}
+	for _, seg := range segments {
+		if seg.kind != literalSegment {
+			panic(fmt.Sprintf("Mux.Group: prefix %q contains parameters, but group contains routes registered with Add", prefix))
+		}
+	}
+	for path, handlers := range group.exacts {
+		for _, h := range handlers {
+			mux.Add(prefix+path, h.Predicate, true, chain(h.Handler, group.middleware))
+		}
+	}
+	for path, handlers := range group.prefixes {
+		for _, h := range handlers {
+			mux.Add(prefix+path, h.Predicate, false, chain(h.Handler, group.middleware))
+		}
+	}
+}
+
+// Mount registers h to handle all requests with paths starting with prefix, regardless of method.
+// It returns the new route, which may be named to build urls of the prefix using [Mux.URL].
+//
+// The prefix is removed from the path of requests before they are passed to h.
+// Requests for the prefix itself are passed with a path of "/".
+// The remaining path is always rooted and clean, and never contains dot segments, even escaped ones.
+// The prefix may contain "{name}" segments, which are then available using [http.Request.PathValue].
+// Mounts are matched like a pattern route ending in a "{name...}" segment, see [Mux.Handle].
+//
+// Mount panics if the prefix is invalid.
+func (mux *Mux) Mount(prefix string, h http.Handler) *Route {
+	segments, err := parsePath(prefix)
+	if err == nil {
+		err = checkPrefix(segments)
+	}
+	var route *Route
+	if err == nil {
+		route, err = newRoute("", append(segments, segment{kind: wildcardSegment}))
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Mux.Mount: invalid prefix %q: %v", prefix, err))
+	}
+
+	route.handler = h
+	route.mount = true
+	mux.insert(route)
+	return route
+}
+
+// checkPrefix checks that segments can be used as a prefix for other routes.
+func checkPrefix(segments []segment) error {
+	for _, seg := range segments {
+		if seg.kind == wildcardSegment {
+			return errWildcardNotLast
+		}
+	}
+	return nil
+}
+
+// stripSegments returns a shallow copy of r with the path replaced by the given segments.
+// segments holds the unescaped segments, raw the escaped ones.
+//
+// Like [NormalizePath], the new path is rooted and cleaned, so it never contains dot segments or repeated slashes.
+// Unlike [NormalizePath], a trailing slash of the original request path is preserved.
+func stripSegments(r *http.Request, segments, raw []string) *http.Request {
+	urlPath := path.Clean("/" + strings.Join(segments, "/"))
+	rawPath := "/" + strings.Join(raw, "/")
+	if len(segments) > 0 && strings.HasSuffix(r.URL.Path, "/") && urlPath != "/" {
+		urlPath += "/"
+		rawPath += "/"
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = urlPath
+	r2.URL.RawPath = ""
+	if rawPath != urlPath {
+		r2.URL.RawPath = rawPath
+	}
+	return r2
+}
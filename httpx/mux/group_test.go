@@ -0,0 +1,147 @@
+package mux_test
+
+//spellchecker:words context http httptest strings testing pkglib httpx
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/httpx"
+	"go.tkw01536.de/pkglib/httpx/mux"
+)
+
+// tag returns middleware that appends a tag to the X-Tags header.
+func tag(name string) mux.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Tags", name)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ExampleMux_Group() {
+	var m mux.Mux
+	m.Use(tag("outer"))
+
+	m.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("home"))
+	})
+	m.Group("/api/{version}", func(api *mux.Mux) {
+		api.Use(tag("api"))
+
+		api.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, "user %s (api %s)", r.PathValue("id"), r.PathValue("version"))
+		}).Name("user")
+	})
+	m.Mount("/static", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "static file %s", r.URL.Path)
+	}))
+
+	for _, path := range []string{"/", "/api/v1/users/42", "/static/css/site.css", "/missing"} {
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, req)
+
+		fmt.Printf("%q returned code %d with tags %v: %q\n", path, rr.Code, rr.Header().Values("X-Tags"), strings.TrimSpace(rr.Body.String()))
+	}
+
+	url, _ := m.URL("user", map[string]string{"version": "v2", "id": "1"})
+	fmt.Println(url)
+
+	// Output: "/" returned code 200 with tags [outer]: "home"
+	// "/api/v1/users/42" returned code 200 with tags [outer api]: "user 42 (api v1)"
+	// "/static/css/site.css" returned code 200 with tags [outer]: "static file /css/site.css"
+	// "/missing" returned code 404 with tags [outer]: "404 page not found"
+	// /api/v2/users/1
+}
+
+func TestMux_Group(t *testing.T) {
+	t.Parallel()
+
+	var m mux.Mux
+	m.Group("/outer", func(outer *mux.Mux) {
+		outer.Use(tag("outer"))
+		outer.Group("/inner", func(inner *mux.Mux) {
+			inner.Use(tag("inner"))
+			inner.Handle("/{name}", httpx.Response{Body: []byte("pattern")})
+			inner.Add("/legacy", nil, true, httpx.Response{Body: []byte("legacy")})
+		})
+		outer.Mount("/mounted", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.URL.Path))
+		}))
+	})
+
+	tests := []struct {
+		path     string
+		wantTags string
+		wantBody string
+	}{
+		{"/outer/inner/x", "outer,inner", "pattern"},
+		{"/outer/inner/legacy", "outer,inner", "legacy"},
+		{"/outer/mounted/a/b", "outer", "/a/b"},
+		{"/outer/inner", "", "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, httptest.NewRequestWithContext(t.Context(), http.MethodGet, tt.path, nil))
+
+			if got := strings.Join(rr.Header().Values("X-Tags"), ","); got != tt.wantTags {
+				t.Errorf("ServeHTTP() ran middleware %q, want %q", got, tt.wantTags)
+			}
+			if got := rr.Body.String(); got != tt.wantBody {
+				t.Errorf("ServeHTTP() wrote %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+
+	var got []string
+	for _, route := range m.Routes() {
+		got = append(got, fmt.Sprintf("%s:%v", route, route.Prefix))
+	}
+	if want := "/outer/inner/legacy/:false,/outer/inner/{name}:false,/outer/mounted/:true"; strings.Join(got, ",") != want {
+		t.Errorf("Routes() = %v, want %s", got, want)
+	}
+}
+
+func TestMux_Mount(t *testing.T) {
+	t.Parallel()
+
+	var m mux.Mux
+	m.Mount("/files/{owner}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.PathValue("owner"), r.URL.Path, r.URL.EscapedPath())
+	}))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/files/alice", "alice / /"},
+		{"/files/alice/", "alice / /"},
+		{"/files/alice/docs", "alice /docs /docs"},
+		{"/files/alice/docs/", "alice /docs/ /docs/"},
+		{"/files/alice/a%2Fb/c", "alice /a/b/c /a%2Fb/c"},
+		{"/files/alice/x/../y", "alice /y /y"},
+		{"/files/alice/a%2F%2Fb", "alice /a/b /a/b"},
+		{"/files/alice/%2e%2e/secret", "404 page not found\n"},
+		{"/files/alice/..%2Fsecret", "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, httptest.NewRequestWithContext(t.Context(), http.MethodPost, tt.path, nil))
+
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("ServeHTTP() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,31 @@
 // Package mux provides [Mux]
 package mux
 
-//spellchecker:words http
+//spellchecker:words http pkglib httpx
 import (
 	"net/http"
+
+	"go.tkw01536.de/pkglib/httpx"
 )
 
 // Mux routes requests to different handlers.
-// See the [Add] for how requests are matched to their handler.
+// See [Mux.Add] and [Mux.Handle] for how requests are matched to their handler.
 type Mux struct {
 	prefixes map[string][]handler
 	exacts   map[string][]handler
 
+	routes []*Route          // pattern routes, ordered by specificity
+	names  map[string]*Route // named pattern routes
+
+	middleware []Middleware // see [Mux.Use]
+
 	// NotFound is called when no prefix is matched.
 	NotFound http.Handler
+
+	// MethodNotAllowed is called when a pattern route matches the path, but not the method of a request.
+	// The "Allow" header is set before it is called.
+	// If nil, a plain text response with status [http.StatusMethodNotAllowed] is written.
+	MethodNotAllowed http.Handler
 }
 
 type handler struct {
@@ -45,8 +57,15 @@ func (mux *Mux) Add(path string, predicate Predicate, exact bool, h http.Handler
 
 // Match returns the handler to be applied for the given request.
 func (mux *Mux) Match(r *http.Request) (http.Handler, bool) {
+	h, _, ok := mux.match(r)
+	return h, ok
+}
+
+// match implements [Mux.Match].
+// It additionally returns the pattern of the matched route, see [Mux.ServeHTTP].
+func (mux *Mux) match(r *http.Request) (http.Handler, string, bool) {
 	if mux == nil {
-		return nil, false
+		return nil, "", false
 	}
 
 	candidate := NormalizePath(r.URL.Path)
@@ -54,22 +73,27 @@ func (mux *Mux) Match(r *http.Request) (http.Handler, bool) {
 	// match the exact path first
 	for _, h := range mux.exacts[candidate] {
 		if h.Predicate.Call(r) {
-			return h.Handler, true
+			return h.Handler, candidate, true
 		}
 	}
 
+	// then match pattern routes
+	if h, pattern, ok := mux.matchRoute(r); ok {
+		return h, pattern, true
+	}
+
 	// iterate over path segment candidates
 	for {
 		// check the current candidate
 		for _, h := range mux.prefixes[candidate] {
 			if h.Predicate.Call(r) {
-				return h.Handler, true
+				return h.Handler, candidate, true
 			}
 		}
 
 		// if the candidate is the root url, we can bail out now
 		if len(candidate) == 0 || candidate == "/" {
-			return nil, false
+			return nil, "", false
 		}
 
 		// move to the parent segment
@@ -78,16 +102,23 @@ func (mux *Mux) Match(r *http.Request) (http.Handler, bool) {
 }
 
 // ServeHTTP serves requests to this mux.
+//
+// When a route matches, r.Pattern is set to its pattern as returned by [RouteInfo.String].
+// This allows wrapping handlers, such as access logs, to determine the matched route.
 func (mux *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// find the right handler, or go into not found mode
-	handler, ok := mux.Match(r)
-	if !ok {
-		if mux == nil || mux.NotFound == nil {
-			http.NotFound(w, r)
-			return
+	handler, pattern, ok := mux.match(r)
+	if ok {
+		r.Pattern = pattern
+		httpx.SetRoute(r, pattern)
+	} else {
+		handler = http.HandlerFunc(http.NotFound)
+		if mux != nil && mux.NotFound != nil {
+			handler = mux.NotFound
 		}
-		mux.NotFound.ServeHTTP(w, r)
-		return
+	}
+	if mux != nil {
+		handler = chain(handler, mux.middleware)
 	}
 
 	// call the actual handling
@@ -0,0 +1,532 @@
+package mux
+
+//spellchecker:words encoding errors http slices strconv strings unicode pkglib httpx
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.tkw01536.de/pkglib/httpx"
+)
+
+// Route is a pattern route registered with [Mux.Handle].
+type Route struct {
+	mux *Mux
+
+	method   string
+	pattern  string
+	segments []segment
+	name     string
+	handler  http.Handler
+
+	mount bool // route was registered using [Mux.Mount], and ends in an unnamed wildcard segment
+}
+
+// segmentKind is the kind of a segment of a pattern.
+// Smaller kinds are more specific.
+type segmentKind int
+
+const (
+	literalSegment  segmentKind = iota // matches a single segment exactly
+	paramSegment                       // matches any single segment
+	wildcardSegment                    // matches all remaining segments
+)
+
+type segment struct {
+	kind  segmentKind
+	value string // literal value, or name of the parameter
+}
+
+// Handle registers h to handle requests matching pattern and returns the new route.
+//
+// A pattern consists of an optional method followed by a path, such as "GET /users/{id}".
+// Routes registered with a method only match requests with the same method;
+// routes registered for [http.MethodGet] also match [http.MethodHead] requests.
+// When a request path is matched by some route, but none of them accept the request method,
+// the mux responds using [Mux.MethodNotAllowed] with an appropriate "Allow" header.
+//
+// The path is normalized using [NormalizePath] and then split into segments.
+// Request paths are normalized in the same way.
+// Requests whose path contains escaped dot segments, such as "%2e%2e", are never matched by pattern routes.
+// A segment of the form "{name}" matches any single non-empty segment.
+// The final segment may be of the form "{name...}" to match all remaining segments, including none at all.
+// All other segments must match exactly.
+// Values of matched segments are available using [http.Request.PathValue] or [Param].
+//
+// When several routes match a request, the most specific one is used.
+// Comparing segments from left to right, literal segments are more specific than "{name}" segments,
+// which are more specific than "{name...}" segments.
+// Among equally specific routes, the one registered first is used.
+// Pattern routes are matched after exact routes and before prefix routes registered using [Mux.Add].
+//
+// Handle panics if pattern is invalid.
+func (mux *Mux) Handle(pattern string, h http.Handler) *Route {
+	route, err := parseRoute(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("Mux.Handle: invalid pattern %q: %v", pattern, err))
+	}
+	route.handler = h
+	mux.insert(route)
+	return route
+}
+
+// insert inserts route into the pattern routes of mux.
+func (mux *Mux) insert(route *Route) {
+	route.mux = mux
+
+	// insert after all routes at least as specific
+	index := len(mux.routes)
+	for i, other := range mux.routes {
+		if compareSpecificity(route.segments, other.segments) < 0 {
+			index = i
+			break
+		}
+	}
+	mux.routes = slices.Insert(mux.routes, index, route)
+}
+
+// HandleFunc is like [Mux.Handle], but takes a function.
+func (mux *Mux) HandleFunc(pattern string, h func(w http.ResponseWriter, r *http.Request)) *Route {
+	return mux.Handle(pattern, http.HandlerFunc(h))
+}
+
+// parseRoute parses a pattern into a new route.
+func parseRoute(pattern string) (*Route, error) {
+	var method string
+
+	path := strings.TrimSpace(pattern)
+	if m, rest, ok := strings.Cut(path, " "); ok {
+		method = m
+		path = strings.TrimSpace(rest)
+	}
+
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return newRoute(method, segments)
+}
+
+// parsePath parses the path of a pattern into segments.
+func parsePath(path string) (segments []segment, err error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, errNoLeadingSlash
+	}
+
+	for _, raw := range splitSegments(NormalizePath(path)) {
+		seg := segment{kind: literalSegment, value: raw}
+		if name, ok := strings.CutPrefix(raw, "{"); ok {
+			if name, ok = strings.CutSuffix(name, "}"); !ok {
+				return nil, fmt.Errorf("%w: %q", errInvalidSegment, raw)
+			}
+			seg.kind = paramSegment
+			if name, ok = strings.CutSuffix(name, "..."); ok {
+				seg.kind = wildcardSegment
+			}
+			if !isParamName(name) {
+				return nil, fmt.Errorf("%w: %q", errInvalidSegment, raw)
+			}
+			seg.value = name
+		}
+		if seg.kind == literalSegment && strings.ContainsAny(raw, "{}") {
+			return nil, fmt.Errorf("%w: %q", errInvalidSegment, raw)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// newRoute creates a new route from the given method and segments.
+// Unnamed parameters are permitted, but not set by the returned route.
+func newRoute(method string, segments []segment) (*Route, error) {
+	route := Route{method: method, segments: segments}
+
+	seen := make(map[string]struct{}, len(segments))
+	for i, seg := range segments {
+		if seg.kind == wildcardSegment && i != len(segments)-1 {
+			return nil, errWildcardNotLast
+		}
+		if seg.kind == literalSegment || seg.value == "" {
+			continue
+		}
+		if _, ok := seen[seg.value]; ok {
+			return nil, fmt.Errorf("%w: %q", errDuplicateParam, seg.value)
+		}
+		seen[seg.value] = struct{}{}
+	}
+
+	parts := make([]string, len(route.segments))
+	for i, seg := range route.segments {
+		parts[i] = seg.String()
+	}
+	route.pattern = "/" + strings.Join(parts, "/")
+	return &route, nil
+}
+
+var (
+	errNoLeadingSlash  = errors.New("path must start with '/'")
+	errInvalidSegment  = errors.New("invalid segment")
+	errDuplicateParam  = errors.New("duplicate parameter")
+	errWildcardNotLast = errors.New("'...' parameter must be the final segment")
+)
+
+// isParamName checks if name is a valid parameter name.
+// This is the case if it is a go identifier.
+func isParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (seg segment) String() string {
+	switch seg.kind {
+	case paramSegment:
+		return "{" + seg.value + "}"
+	case wildcardSegment:
+		return "{" + seg.value + "...}"
+	case literalSegment:
+		return seg.value
+	}
+	panic("never reached")
+}
+
+// splitSegments splits a normalized path into its segments.
+func splitSegments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// compareSpecificity compares the specificity of two routes.
+// It returns a negative number if a is more specific than b, a positive number if b is more specific than a,
+// and zero if they are equally specific.
+func compareSpecificity(a, b []segment) int {
+	for i := range max(len(a), len(b)) {
+		// a missing segment can only be matched by an empty wildcard, so it is as specific as a literal.
+		ak, bk := literalSegment, literalSegment
+		if i < len(a) {
+			ak = a[i].kind
+		}
+		if i < len(b) {
+			bk = b[i].kind
+		}
+		if ak != bk {
+			return int(ak) - int(bk)
+		}
+	}
+	return 0
+}
+
+// match matches the segments of a request path against this route.
+// If they match, returns the values of parameters in order of segments.
+func (route *Route) match(segments []string) (values []string, ok bool) {
+	for i, seg := range route.segments {
+		switch seg.kind {
+		case wildcardSegment:
+			return append(values, strings.Join(segments[i:], "/")), true
+		case paramSegment:
+			if i >= len(segments) {
+				return nil, false
+			}
+			values = append(values, segments[i])
+		case literalSegment:
+			if i >= len(segments) || segments[i] != seg.value {
+				return nil, false
+			}
+		}
+	}
+	return values, len(segments) == len(route.segments)
+}
+
+// hasDotSegment checks if the unescaped segment is a dot segment, or contains one when split at escaped slashes.
+func hasDotSegment(segment string) bool {
+	for part := range strings.SplitSeq(segment, "/") {
+		if part == "." || part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// allows checks if this route accepts the given method.
+func (route *Route) allows(method string) bool {
+	return route.method == "" || route.method == method || (route.method == http.MethodGet && method == http.MethodHead)
+}
+
+// matchRoute finds the pattern route to be applied for the given request.
+// pattern is the pattern of the route, as returned by [RouteInfo.String], or the empty string if no route accepts the method.
+func (mux *Mux) matchRoute(r *http.Request) (http.Handler, string, bool) {
+	if len(mux.routes) == 0 {
+		return nil, "", false
+	}
+
+	// split the escaped path, so that escaped slashes do not separate segments.
+	raw := splitSegments(NormalizePath(r.URL.EscapedPath()))
+	segments := make([]string, len(raw))
+	for i, seg := range raw {
+		segments[i] = seg
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			segments[i] = unescaped
+		}
+
+		// normalizing removed all plain dot segments, but not escaped ones.
+		// never pass those on to handlers.
+		if hasDotSegment(segments[i]) {
+			return nil, "", false
+		}
+	}
+
+	var allowed []string
+	for _, route := range mux.routes {
+		values, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if !route.allows(r.Method) {
+			allowed = append(allowed, route.method)
+			if route.method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+			continue
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			index := 0
+			for _, seg := range route.segments {
+				if seg.kind == literalSegment {
+					continue
+				}
+				if seg.value != "" {
+					r.SetPathValue(seg.value, values[index])
+				}
+				index++
+			}
+			if route.mount {
+				rest := len(route.segments) - 1
+				r = stripSegments(r, segments[rest:], raw[rest:])
+			}
+			route.handler.ServeHTTP(w, r)
+		}), route.info().String(), true
+	}
+
+	if len(allowed) == 0 {
+		return nil, "", false
+	}
+
+	slices.Sort(allowed)
+	allow := strings.Join(slices.Compact(allowed), ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if mux.MethodNotAllowed == nil {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		mux.MethodNotAllowed.ServeHTTP(w, r)
+	}), "", true
+}
+
+// Name assigns a name to this route, to be used with [Mux.URL].
+// It returns the route itself.
+//
+// Name panics if the name is already in use by a different route of the same mux.
+func (route *Route) Name(name string) *Route {
+	if other, ok := route.mux.names[name]; ok && other != route {
+		panic(fmt.Sprintf("Route.Name: name %q already in use", name))
+	}
+	if route.mux.names == nil {
+		route.mux.names = make(map[string]*Route)
+	}
+	if route.name != "" {
+		delete(route.mux.names, route.name)
+	}
+
+	route.name = name
+	route.mux.names[name] = route
+	return route
+}
+
+var (
+	// ErrUnknownRoute indicates that no route with the given name exists.
+	ErrUnknownRoute = errors.New("unknown route")
+	// ErrMissingParam indicates that a parameter required to build a url was not provided.
+	ErrMissingParam = errors.New("missing parameter")
+	// ErrInvalidParam indicates that the value of a parameter would result in a url not matching the route.
+	ErrInvalidParam = errors.New("invalid parameter")
+)
+
+// URL builds the path of the route with the given name.
+// Parameters of the route are substituted with the corresponding values in params, and escaped as needed.
+// Values of "{name...}" parameters may contain slashes and be empty; other values must be non-empty.
+// Values must not contain "." or ".." segments, as such urls are never matched; see [Mux.Handle].
+// Parameters without a corresponding segment in the route are ignored.
+func (mux *Mux) URL(name string, params map[string]string) (string, error) {
+	route, ok := mux.names[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRoute, name)
+	}
+
+	var builder strings.Builder
+	for _, seg := range route.segments {
+		switch seg.kind {
+		case literalSegment:
+			builder.WriteString("/")
+			builder.WriteString(url.PathEscape(seg.value))
+		case paramSegment:
+			value := params[seg.value]
+			if value == "" {
+				return "", fmt.Errorf("%w: %q", ErrMissingParam, seg.value)
+			}
+			if hasDotSegment(value) {
+				return "", fmt.Errorf("%w: %q", ErrInvalidParam, seg.value)
+			}
+			builder.WriteString("/")
+			builder.WriteString(url.PathEscape(value))
+		case wildcardSegment:
+			for part := range strings.SplitSeq(params[seg.value], "/") {
+				if part == "" {
+					continue
+				}
+				if part == "." || part == ".." {
+					return "", fmt.Errorf("%w: %q", ErrInvalidParam, seg.value)
+				}
+				builder.WriteString("/")
+				builder.WriteString(url.PathEscape(part))
+			}
+		}
+	}
+
+	if builder.Len() == 0 {
+		return "/", nil
+	}
+	return builder.String(), nil
+}
+
+// Param parses the path parameter with the given name of r into a value of type T.
+// The raw value of the parameter is determined using [http.Request.PathValue].
+//
+// T may be string, bool, int, int64, uint64 or float64, or a type whose pointer implements [encoding.TextUnmarshaler].
+// When the value cannot be parsed, the returned error wraps [httpx.ErrBadRequest].
+func Param[T any](r *http.Request, name string) (T, error) {
+	var value T
+	raw := r.PathValue(name)
+
+	var err error
+	switch v := any(&value).(type) {
+	case *string:
+		*v = raw
+	case *bool:
+		*v, err = strconv.ParseBool(raw)
+	case *int:
+		*v, err = strconv.Atoi(raw)
+	case *int64:
+		*v, err = strconv.ParseInt(raw, 10, 64)
+	case *uint64:
+		*v, err = strconv.ParseUint(raw, 10, 64)
+	case *float64:
+		*v, err = strconv.ParseFloat(raw, 64)
+	case encoding.TextUnmarshaler:
+		err = v.UnmarshalText([]byte(raw))
+	default:
+		return value, fmt.Errorf("%w: %T", errUnsupportedParam, value)
+	}
+
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("%w: invalid path parameter %q: %w", httpx.ErrBadRequest, name, err)
+	}
+	return value, nil
+}
+
+var errUnsupportedParam = errors.New("unsupported parameter type")
+
+// RouteInfo describes a route registered with a [Mux].
+type RouteInfo struct {
+	Method  string // method accepted by the route, empty if any method is accepted
+	Pattern string // path pattern of the route, or path passed to [Mux.Add]
+	Name    string // name of the route, if any
+
+	Params []string // names of parameters of the route, in order
+
+	Prefix    bool // route was registered using [Mux.Add] or [Mux.Mount] and matches all paths starting with Pattern
+	Predicate bool // route was registered using [Mux.Add] with a non-nil predicate
+}
+
+// String formats this route similar to a pattern passed to [Mux.Handle].
+func (info RouteInfo) String() string {
+	if info.Method == "" {
+		return info.Pattern
+	}
+	return info.Method + " " + info.Pattern
+}
+
+// Routes returns information about all routes registered with this mux.
+// Routes are sorted by pattern and then by method.
+func (mux *Mux) Routes() []RouteInfo {
+	if mux == nil {
+		return nil
+	}
+
+	routes := make([]RouteInfo, 0, len(mux.routes))
+	for _, route := range mux.routes {
+		routes = append(routes, route.info())
+	}
+	for path, handlers := range mux.exacts {
+		for _, h := range handlers {
+			routes = append(routes, RouteInfo{Pattern: path, Predicate: h.Predicate != nil})
+		}
+	}
+	for path, handlers := range mux.prefixes {
+		for _, h := range handlers {
+			routes = append(routes, RouteInfo{Pattern: path, Prefix: true, Predicate: h.Predicate != nil})
+		}
+	}
+
+	slices.SortStableFunc(routes, compareRouteInfo)
+	return routes
+}
+
+// info returns information about this route.
+func (route *Route) info() RouteInfo {
+	info := RouteInfo{Method: route.method, Pattern: route.pattern, Name: route.name}
+	if route.mount {
+		info.Pattern, info.Prefix = strings.TrimSuffix(route.pattern, "{...}"), true
+	}
+	for _, seg := range route.segments {
+		if seg.kind != literalSegment && seg.value != "" {
+			info.Params = append(info.Params, seg.value)
+		}
+	}
+	return info
+}
+
+// compareRouteInfo compares route information by pattern, method and then kind of route.
+func compareRouteInfo(a, b RouteInfo) int {
+	if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Method, b.Method); c != 0 {
+		return c
+	}
+	switch {
+	case a.Prefix == b.Prefix:
+		return 0
+	case b.Prefix:
+		return -1
+	default:
+		return 1
+	}
+}
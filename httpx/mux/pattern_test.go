@@ -0,0 +1,227 @@
+package mux_test
+
+//spellchecker:words context errors http httptest testing pkglib httpx
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.tkw01536.de/pkglib/httpx"
+	"go.tkw01536.de/pkglib/httpx/mux"
+)
+
+func ExampleMux_Handle() {
+	var m mux.Mux
+
+	m.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := mux.Param[int](r, "id")
+		if httpx.TextInterceptor.Intercept(w, r, err) {
+			return
+		}
+		_, _ = fmt.Fprintf(w, "user %d", id)
+	}).Name("user")
+	m.HandleFunc("GET /users/{id}/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "file %q of user %s", r.PathValue("path"), r.PathValue("id"))
+	}).Name("file")
+	m.HandleFunc("DELETE /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	makeRequest := func(method, path string) {
+		req := httptest.NewRequestWithContext(context.Background(), method, path, nil)
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, req)
+
+		result, _ := io.ReadAll(rr.Result().Body)
+		fmt.Printf("%s %q returned code %d (Allow %q) %q\n", method, path, rr.Code, rr.Header().Get("Allow"), string(result))
+	}
+
+	makeRequest(http.MethodGet, "/users/42")
+	makeRequest(http.MethodGet, "/users/me")
+	makeRequest(http.MethodGet, "/users/42/files/docs/a%20b.txt")
+	makeRequest(http.MethodDelete, "/users/42")
+	makeRequest(http.MethodPost, "/users/42")
+
+	url, _ := m.URL("file", map[string]string{"id": "42", "path": "docs/a b.txt"})
+	fmt.Println(url)
+
+	for _, route := range m.Routes() {
+		fmt.Printf("%s %q\n", route, route.Name)
+	}
+
+	// Output: GET "/users/42" returned code 200 (Allow "") "user 42"
+	// GET "/users/me" returned code 400 (Allow "") "Bad Request"
+	// GET "/users/42/files/docs/a%20b.txt" returned code 200 (Allow "") "file \"docs/a b.txt\" of user 42"
+	// DELETE "/users/42" returned code 204 (Allow "") ""
+	// POST "/users/42" returned code 405 (Allow "DELETE, GET, HEAD") "Method Not Allowed\n"
+	// /users/42/files/docs/a%20b.txt
+	// DELETE /users/{id} ""
+	// GET /users/{id} "user"
+	// GET /users/{id}/files/{path...} "file"
+}
+
+func TestMux_Handle(t *testing.T) {
+	t.Parallel()
+
+	var m mux.Mux
+	for _, pattern := range []string{
+		"/",
+		"/static/{path...}",
+		"/{name}",
+		"/users/{id}",
+		"/users/new",
+		"/users/{id}/{action}",
+		"/{first}/{second}",
+		"/escaped/{value}",
+	} {
+		m.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, pattern)
+			for _, name := range []string{"path", "name", "id", "action", "first", "second", "value"} {
+				if value := r.PathValue(name); value != "" {
+					_, _ = fmt.Fprintf(w, " %s=%s", name, value)
+				}
+			}
+		})
+	}
+	m.Add("/legacy", nil, false, httpx.Response{Body: []byte("legacy")})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"/static", "/static/{path...}"},
+		{"/static/", "/static/{path...}"},
+		{"/static/css/site.css", "/static/{path...} path=css/site.css"},
+		{"/static/../users/1", "/users/{id} id=1"},
+		{"/about", "/{name} name=about"},
+		{"/users/new", "/users/new"},
+		{"/users/new/", "/users/new"},
+		{"/users/1", "/users/{id} id=1"},
+		{"/users/1/edit", "/users/{id}/{action} id=1 action=edit"},
+		{"/a/b", "/{first}/{second} first=a second=b"},
+		{"/escaped/a%2Fb", "/escaped/{value} value=a/b"},
+		{"/a/b/c", "not found"},
+		{"/static/../../etc/passwd", "/{first}/{second} first=etc second=passwd"},
+		{"/static/%2e%2e/%2e%2e/etc/passwd", "not found"},
+		{"/static/%2E/file", "not found"},
+		{"/escaped/..%2F..%2Fetc", "not found"},
+		{"/legacy", "/{name} name=legacy"},
+		{"/legacy/sub/deeper", "legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, tt.path, nil)
+			h, ok := m.Match(req)
+
+			got := "not found"
+			if ok {
+				rr := httptest.NewRecorder()
+				h.ServeHTTP(rr, req)
+				got = rr.Body.String()
+			}
+			if got != tt.want {
+				t.Errorf("Match(%q) served %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMux_Handle_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, pattern := range []string{
+		"",
+		"GET",
+		"users/{id}",
+		"/users/{id",
+		"/users/{}",
+		"/users/{1}",
+		"/users/x{id}",
+		"/users/{id}/{id}",
+		"/files/{path...}/more",
+	} {
+		t.Run(pattern, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Handle(%q) did not panic", pattern)
+				}
+			}()
+
+			var m mux.Mux
+			m.Handle(pattern, httpx.ErrNotFound)
+		})
+	}
+}
+
+func TestMux_URL(t *testing.T) {
+	t.Parallel()
+
+	var m mux.Mux
+	m.Handle("/", httpx.ErrNotFound).Name("root")
+	m.Handle("GET /users/{id}/files/{path...}", httpx.ErrNotFound).Name("file")
+
+	tests := []struct {
+		name    string
+		route   string
+		params  map[string]string
+		want    string
+		wantErr error
+	}{
+		{"root", "root", nil, "/", nil},
+		{"params", "file", map[string]string{"id": "a/b", "path": "c/d"}, "/users/a%2Fb/files/c/d", nil},
+		{"empty wildcard", "file", map[string]string{"id": "1"}, "/users/1/files", nil},
+		{"missing param", "file", map[string]string{"path": "x"}, "", mux.ErrMissingParam},
+		{"dot param", "file", map[string]string{"id": "."}, "", mux.ErrInvalidParam},
+		{"dot dot param", "file", map[string]string{"id": ".."}, "", mux.ErrInvalidParam},
+		{"param with escaped dot segment", "file", map[string]string{"id": "a/.."}, "", mux.ErrInvalidParam},
+		{"dot in param", "file", map[string]string{"id": "a.b", "path": "..c"}, "/users/a.b/files/..c", nil},
+		{"dot dot in wildcard", "file", map[string]string{"id": "1", "path": "a/../b"}, "", mux.ErrInvalidParam},
+		{"unknown route", "missing", nil, "", mux.ErrUnknownRoute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := m.URL(tt.route, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("URL() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("URL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParam(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.SetPathValue("number", "42")
+	req.SetPathValue("word", "hello")
+
+	if got, err := mux.Param[int](req, "number"); err != nil || got != 42 {
+		t.Errorf("Param[int](number) = %v, %v, want 42, nil", got, err)
+	}
+	if got, err := mux.Param[float64](req, "number"); err != nil || got != 42 {
+		t.Errorf("Param[float64](number) = %v, %v, want 42, nil", got, err)
+	}
+	if got, err := mux.Param[string](req, "word"); err != nil || got != "hello" {
+		t.Errorf("Param[string](word) = %v, %v, want hello, nil", got, err)
+	}
+	if _, err := mux.Param[int](req, "word"); !errors.Is(err, httpx.ErrBadRequest) {
+		t.Errorf("Param[int](word) error = %v, want %v", err, httpx.ErrBadRequest)
+	}
+	if _, err := mux.Param[[]int](req, "number"); err == nil || errors.Is(err, httpx.ErrBadRequest) {
+		t.Errorf("Param[[]int](number) error = %v, want unsupported type", err)
+	}
+}
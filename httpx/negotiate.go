@@ -0,0 +1,129 @@
+//spellchecker:words httpx
+package httpx
+
+//spellchecker:words http strconv strings
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Negotiate determines which of the offered media types best matches the Accept header of r.
+// Offers are media types such as "text/html", optionally with parameters such as "text/html; charset=utf-8".
+// The offer is returned exactly as passed.
+//
+// Each offer is assigned the quality value of the most specific media range in the Accept header matching it.
+// Media ranges may use wildcards, such as "text/*" or "*/*".
+// Parameters of offers and media ranges, other than the quality value "q", are ignored.
+// The offer with the highest quality is returned; ties are broken by the order of offers.
+//
+// If r has no Accept header, the first offer is returned.
+// If no offer is acceptable, the empty string is returned.
+func Negotiate(r *http.Request, offers ...string) string {
+	index, _ := negotiate(r, offers)
+	if index < 0 {
+		return ""
+	}
+	return offers[index]
+}
+
+// negotiate implements [Negotiate], returning the index of the best offer, or -1 if no offer is acceptable.
+// specific indicates if the Accept header expressed a preference for the chosen offer,
+// that is it was matched by something other than "*/*" or an absent header.
+func negotiate(r *http.Request, offers []string) (index int, specific bool) {
+	ranges := parseAccept(r.Header.Values("Accept"))
+	if len(ranges) == 0 {
+		if len(offers) == 0 {
+			return -1, false
+		}
+		return 0, false
+	}
+
+	index = -1
+	var best mediaRange
+	for i, offer := range offers {
+		typ, subtype := splitMediaType(offer)
+
+		// find the most specific range matching this offer
+		match := mediaRange{specificity: -1}
+		for _, rng := range ranges {
+			if rng.specificity > match.specificity && rng.matches(typ, subtype) {
+				match = rng
+			}
+		}
+
+		if match.specificity >= 0 && match.q > 0 && (index < 0 || match.q > best.q) {
+			index, best = i, match
+		}
+	}
+
+	return index, index >= 0 && best.specificity > 0
+}
+
+// mediaRange is a single media range of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+	specificity  int // 0 for "*/*", 1 for "type/*", 2 for "type/subtype"
+}
+
+func (rng mediaRange) matches(typ, subtype string) bool {
+	return (rng.typ == "*" || rng.typ == typ) && (rng.subtype == "*" || rng.subtype == subtype)
+}
+
+// parseAccept parses the values of Accept headers into media ranges.
+// Invalid media ranges are skipped.
+func parseAccept(values []string) (ranges []mediaRange) {
+	for _, value := range values {
+		for element := range strings.SplitSeq(value, ",") {
+			mediaType, params, _ := strings.Cut(element, ";")
+
+			var rng mediaRange
+			rng.typ, rng.subtype = splitMediaType(mediaType)
+			switch {
+			case rng.typ == "" || rng.subtype == "":
+				continue
+			case rng.typ == "*" && rng.subtype != "*":
+				continue
+			case rng.typ == "*":
+				rng.specificity = 0
+			case rng.subtype == "*":
+				rng.specificity = 1
+			default:
+				rng.specificity = 2
+			}
+
+			var valid bool
+			if rng.q, valid = parseQuality(params); valid {
+				ranges = append(ranges, rng)
+			}
+		}
+	}
+	return ranges
+}
+
+// parseQuality parses the quality value "q" from the ";"-separated parameters of an element of an Accept-style header.
+// If no quality value is present, it defaults to 1.
+// valid is false if the quality value is invalid.
+func parseQuality(params string) (q float64, valid bool) {
+	q = 1
+	for param := range strings.SplitSeq(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return 0, false
+		}
+		q = parsed
+	}
+	return q, true
+}
+
+// splitMediaType splits a media type into lower-case type and subtype, ignoring any parameters.
+func splitMediaType(mediaType string) (typ, subtype string) {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	typ, subtype, _ = strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+	return strings.TrimSpace(typ), strings.TrimSpace(subtype)
+}
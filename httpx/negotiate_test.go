@@ -0,0 +1,142 @@
+//spellchecker:words httpx
+package httpx_test
+
+//spellchecker:words context errors http httptest testing pkglib httpx
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.tkw01536.de/pkglib/httpx"
+)
+
+func ExampleNegotiate() {
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html;q=0.8, application/json, */*;q=0.1")
+
+	fmt.Println(httpx.Negotiate(req, "text/html", "application/json"))
+	fmt.Println(httpx.Negotiate(req, "text/html", "text/plain"))
+	fmt.Println(httpx.Negotiate(req, "image/png"))
+	// Output: application/json
+	// text/html
+	// image/png
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	offers := []string{"text/html; charset=utf-8", "application/json", "text/plain"}
+
+	tests := []struct {
+		name   string
+		accept []string
+		want   string
+	}{
+		{"no header", nil, "text/html; charset=utf-8"},
+		{"exact", []string{"application/json"}, "application/json"},
+		{"case insensitive", []string{"Application/JSON"}, "application/json"},
+		{"wildcard subtype", []string{"text/*"}, "text/html; charset=utf-8"},
+		{"wildcard", []string{"*/*"}, "text/html; charset=utf-8"},
+		{"quality", []string{"text/html;q=0.5, text/plain;q=0.9"}, "text/plain"},
+		{"most specific wins", []string{"text/*;q=0.9, text/html;q=0.1"}, "text/plain"},
+		{"excluded", []string{"text/html;q=0, */*"}, "application/json"},
+		{"multiple headers", []string{"image/png", "text/plain"}, "text/plain"},
+		{"not acceptable", []string{"image/png"}, ""},
+		{"invalid quality", []string{"text/html;q=2, text/plain"}, "text/plain"},
+		{"invalid range", []string{"*/html"}, "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			for _, accept := range tt.accept {
+				req.Header.Add("Accept", accept)
+			}
+			if got := httpx.Negotiate(req, offers...); got != tt.want {
+				t.Errorf("Negotiate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiatingInterceptor(t *testing.T) {
+	t.Parallel()
+
+	interceptor := httpx.NegotiatingInterceptor(httpx.JSONInterceptor)
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", httpx.ContentTypeHTML},
+		{"curl", "*/*", httpx.ContentTypeJSON},
+		{"no header", "", httpx.ContentTypeJSON},
+		{"plain text", "text/plain", httpx.ContentTypeText},
+		{"unsupported", "image/png", httpx.ContentTypeJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			interceptor.Intercept(rr, req, httpx.ErrNotFound)
+
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("Intercept() wrote status %d, want %d", rr.Code, http.StatusNotFound)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.want {
+				t.Errorf("Intercept() wrote content type %q, want %q", got, tt.want)
+			}
+			if got := rr.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Intercept() wrote Vary %q, want %q", got, "Accept")
+			}
+		})
+	}
+}
+
+func TestNegotiatingInterceptor_classify(t *testing.T) {
+	t.Parallel()
+
+	problems := httpx.ProblemInterceptor()
+	problems.Errors[errBalance] = httpx.Response{StatusCode: http.StatusPaymentRequired}
+	interceptor := httpx.NegotiatingInterceptor(problems)
+
+	tests := []struct {
+		name       string
+		accept     string
+		err        error
+		wantStatus int
+		wantType   string
+	}{
+		{"custom mapping", "application/json", errBalance, http.StatusPaymentRequired, httpx.ContentTypeProblemJSON},
+		{"custom mapping for browser", "text/html", errBalance, http.StatusPaymentRequired, httpx.ContentTypeProblemJSON},
+		{"common status", "text/plain", httpx.ErrNotFound, http.StatusNotFound, httpx.ContentTypeText},
+		{"unknown error", "application/json", errors.New("unknown"), http.StatusInternalServerError, httpx.ContentTypeJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+			interceptor.Intercept(rr, req, tt.err)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Intercept() wrote status %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Intercept() wrote content type %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
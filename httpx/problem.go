@@ -0,0 +1,138 @@
+//spellchecker:words httpx
+package httpx
+
+//spellchecker:words encoding json errors http maps pkglib errorsx
+import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"go.tkw01536.de/pkglib/errorsx"
+)
+
+// ContentTypeProblemJSON is the content type of problem details, see [Problem].
+const ContentTypeProblemJSON = "application/problem+json"
+
+// Problem represents problem details as defined in RFC 9457.
+//
+// Problem implements [http.Handler].
+// When used as a handler, it writes itself as a response of type [ContentTypeProblemJSON].
+type Problem struct {
+	Type     string // URI reference identifying the problem type, "about:blank" when empty
+	Title    string // short human-readable summary of the problem type
+	Status   int    // http status code
+	Detail   string // human-readable explanation specific to this occurrence
+	Instance string // URI reference identifying this occurrence
+
+	// Extensions holds additional members of the problem details.
+	// Members named like one of the standard members above are ignored.
+	Extensions map[string]any
+}
+
+// ProblemExtender can be implemented by errors to contribute to the problem details rendered for them.
+// See [ProblemInterceptor].
+type ProblemExtender interface {
+	error
+
+	// ExtendProblem modifies problem details for this error.
+	// Typically, it sets Type or Detail, or adds Extensions.
+	ExtendProblem(problem *Problem)
+}
+
+// MarshalJSON encodes the problem details as a json object.
+// Empty standard members are omitted; members are sorted by name.
+func (problem Problem) MarshalJSON() ([]byte, error) {
+	members := maps.Clone(problem.Extensions)
+	if members == nil {
+		members = make(map[string]any, 5)
+	}
+
+	for name, value := range map[string]string{
+		"type":     problem.Type,
+		"title":    problem.Title,
+		"detail":   problem.Detail,
+		"instance": problem.Instance,
+	} {
+		delete(members, name)
+		if value != "" {
+			members[name] = value
+		}
+	}
+	delete(members, "status")
+	if problem.Status != 0 {
+		members["status"] = problem.Status
+	}
+
+	data, err := json.Marshal(members, json.Deterministic(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal problem details: %w", err)
+	}
+	return data, nil
+}
+
+// ServeHTTP implements [http.Handler].
+func (problem Problem) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := problem.MarshalJSON()
+	if err != nil {
+		// extensions could not be encoded, so fall back to the standard members only.
+		problem.Extensions = nil
+		data, _ = problem.MarshalJSON()
+	}
+
+	status := problem.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblemJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// RenderProblem renders err as problem details.
+// It can be used as [ErrInterceptor.Render].
+//
+// The status code is taken from res, and the title is the corresponding status text.
+// The detail is the message of err that is safe to show to users, see [errorsx.UserMessage].
+// The instance is the path of the request.
+// If err wraps a [ProblemExtender], it is used to modify the problem details before they are written.
+func RenderProblem(w http.ResponseWriter, r *http.Request, err error, res Response) {
+	status := res.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+
+	problem := Problem{Title: http.StatusText(status), Status: status}
+	if message, ok := errorsx.UserMessage(err); ok {
+		problem.Detail = message
+	}
+	if r != nil && r.URL != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	var extender ProblemExtender
+	if errors.As(err, &extender) {
+		extender.ExtendProblem(&problem)
+	}
+
+	problem.ServeHTTP(w, r)
+}
+
+// ProblemInterceptor returns a new interceptor that renders errors as problem details using [RenderProblem].
+//
+// It handles the same errors as [JSONInterceptor].
+// Further errors can be added to the Errors field of the returned interceptor;
+// only the status codes of their responses are used.
+func ProblemInterceptor() ErrInterceptor {
+	interceptor := commonInterceptor(ContentTypeProblemJSON, func(code StatusCode) []byte {
+		data, err := Problem{Title: code.String(), Status: int(code)}.MarshalJSON()
+		if err != nil {
+			panic(err)
+		}
+		return data
+	})
+	interceptor.Render = RenderProblem
+	return interceptor
+}
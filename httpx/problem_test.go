@@ -0,0 +1,93 @@
+//spellchecker:words httpx
+package httpx_test
+
+//spellchecker:words context errors http httptest testing pkglib errorsx httpx
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.tkw01536.de/pkglib/errorsx"
+	"go.tkw01536.de/pkglib/httpx"
+)
+
+// balanceError is an error contributing to problem details.
+type balanceError struct {
+	Balance int
+}
+
+func (be balanceError) Error() string {
+	return "insufficient balance"
+}
+
+func (be balanceError) ExtendProblem(problem *httpx.Problem) {
+	problem.Type = "https://example.com/problems/balance"
+	problem.Detail = fmt.Sprintf("your balance is %d", be.Balance)
+	problem.Extensions = map[string]any{"balance": be.Balance, "status": "ignored"}
+}
+
+var errBalance = errors.New("balance")
+
+func ExampleProblemInterceptor() {
+	interceptor := httpx.ProblemInterceptor()
+	interceptor.Errors[errBalance] = httpx.Response{StatusCode: http.StatusPaymentRequired}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := map[string]error{
+			"/":        nil,
+			"/missing": errorsx.WithUserMessage(fmt.Errorf("no such account: %w", httpx.ErrNotFound), "the account does not exist"),
+			"/balance": fmt.Errorf("%w: %w", errBalance, balanceError{Balance: 42}),
+		}[r.URL.Path]
+
+		if interceptor.Intercept(w, r, result) {
+			return
+		}
+		_, _ = w.Write([]byte("Normal response"))
+	})
+
+	for _, path := range []string{"/", "/missing", "/balance"} {
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		fmt.Printf("%s returned %d with %s %s\n", path, rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	// Output: / returned 200 with text/plain; charset=utf-8 Normal response
+	// /missing returned 404 with application/problem+json {"detail":"the account does not exist","instance":"/missing","status":404,"title":"Not Found"}
+	// /balance returned 402 with application/problem+json {"balance":42,"detail":"your balance is 42","instance":"/balance","status":402,"title":"Payment Required","type":"https://example.com/problems/balance"}
+}
+
+func TestProblem_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		problem    httpx.Problem
+		wantStatus int
+		wantBody   string
+	}{
+		{"empty", httpx.Problem{}, http.StatusInternalServerError, `{}`},
+		{"standard members", httpx.Problem{Title: "Conflict", Status: http.StatusConflict}, http.StatusConflict, `{"status":409,"title":"Conflict"}`},
+		{"extensions do not override", httpx.Problem{Title: "Gone", Status: http.StatusGone, Extensions: map[string]any{"title": "overridden", "id": 1}}, http.StatusGone, `{"id":1,"status":410,"title":"Gone"}`},
+		{"invalid extensions", httpx.Problem{Status: http.StatusTeapot, Extensions: map[string]any{"invalid": make(chan int)}}, http.StatusTeapot, `{"status":418}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			tt.problem.ServeHTTP(rr, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() wrote status %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Body.String(); got != tt.wantBody {
+				t.Errorf("ServeHTTP() wrote body %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
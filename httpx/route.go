@@ -0,0 +1,54 @@
+//spellchecker:words httpx
+package httpx
+
+//spellchecker:words context http sync
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// routeHolderKey is the context key of a [RouteHolder].
+type routeHolderKey struct{}
+
+// RouteHolder holds the route a request was matched to.
+//
+// A RouteHolder is stored in the context of a request using [WithRouteHolder], and filled in by routers using [SetRoute].
+// Unlike [http.Request.Pattern], it thus survives copies of the request, such as those made by [http.Request.WithContext].
+//
+// The zero value holds no route.
+// A RouteHolder is safe for concurrent use.
+type RouteHolder struct {
+	m     sync.Mutex
+	route string
+}
+
+// Route returns the route held, or the empty string if no route has been set.
+func (holder *RouteHolder) Route() string {
+	holder.m.Lock()
+	defer holder.m.Unlock()
+
+	return holder.route
+}
+
+// WithRouteHolder returns a copy of r whose context holds holder.
+func WithRouteHolder(r *http.Request, holder *RouteHolder) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeHolderKey{}, holder))
+}
+
+// SetRoute sets the route held by the [RouteHolder] in the context of r.
+// If the context of r holds no RouteHolder, SetRoute does nothing.
+//
+// When a request passes through multiple routers, e.g. a router mounted inside another one,
+// the route set last, i.e. by the innermost router, is kept.
+func SetRoute(r *http.Request, route string) {
+	holder, ok := r.Context().Value(routeHolderKey{}).(*RouteHolder)
+	if !ok {
+		return
+	}
+
+	holder.m.Lock()
+	defer holder.m.Unlock()
+
+	holder.route = route
+}
@@ -0,0 +1,243 @@
+//spellchecker:words wrap
+package wrap
+
+//spellchecker:words bufio context slog http time pkglib httpx
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"go.tkw01536.de/pkglib/httpx"
+)
+
+// AccessLogOptions configure [AccessLog].
+type AccessLogOptions struct {
+	// Logger is the logger to write entries to.
+	// A nil Logger uses [slog.Default].
+	Logger *slog.Logger
+
+	// Message is the message of every entry, defaulting to "request".
+	Message string
+
+	// Levels determines the level of entries by status class, e.g. 4 for all 4xx status codes.
+	// Classes not contained in Levels use [slog.LevelError] for 5xx, [slog.LevelWarn] for 4xx and [slog.LevelInfo] otherwise.
+	Levels map[int]slog.Level
+
+	// RequestID returns the id of a request.
+	// A nil RequestID uses the "X-Request-Id" header of the request.
+	RequestID func(r *http.Request) string
+
+	// Route returns the route a request was matched to.
+	// It is called after the request has been handled.
+	// A nil Route uses the route set using [httpx.SetRoute], such as by the mux package,
+	// and otherwise falls back to [http.Request.Pattern], as set by [http.ServeMux].
+	Route func(r *http.Request) string
+}
+
+// AccessLog wraps handler, logging an entry for every request once it has been handled.
+//
+// Entries contain the method, path, status code, number of bytes written, duration, remote address,
+// and if available route and request id of each request.
+// The duration is measured from the start time stored by an enclosing [Time], see [TimeSince].
+// If the incoming request holds no start time, AccessLog stores the current time instead.
+//
+// Handler should not be wrapped with [Time], as an inner Time records a second, later start time, which is not seen by AccessLog.
+// The route is recorded using an [httpx.RouteHolder] stored in the request context.
+// It is therefore logged even if the router only receives a copy of the request, such as from [Time], [Context] or a mount.
+//
+// The [http.ResponseWriter] passed to handler implements [http.Flusher], [http.Hijacker] and [io.ReaderFrom]
+// if and only if the original writer does.
+// A request that is hijacked is logged with status [http.StatusSwitchingProtocols], unless a different status was written.
+func AccessLog(handler http.Handler, opts AccessLogOptions) http.Handler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	message := opts.Message
+	if message == "" {
+		message = "request"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(requestTimeKey).(time.Time); !ok {
+			r = r.WithContext(context.WithValue(r.Context(), requestTimeKey, time.Now())) //nolint:contextcheck // explicitly use the context
+		}
+
+		var holder httpx.RouteHolder
+		r = httpx.WithRouteHolder(r, &holder)
+
+		rec := &recorder{ResponseWriter: w}
+		handler.ServeHTTP(rec.wrap(), r)
+
+		status := rec.status
+		switch {
+		case status == 0 && rec.hijacked:
+			status = http.StatusSwitchingProtocols
+		case status == 0:
+			status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", TimeSince(r)),
+			slog.String("remote", r.RemoteAddr),
+		}
+
+		route := holder.Route()
+		if route == "" {
+			route = r.Pattern
+		}
+		if opts.Route != nil {
+			route = opts.Route(r)
+		}
+		if route != "" {
+			attrs = append(attrs, slog.String("route", route))
+		}
+
+		id := r.Header.Get("X-Request-Id")
+		if opts.RequestID != nil {
+			id = opts.RequestID(r)
+		}
+		if id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+
+		logger.LogAttrs(r.Context(), opts.level(status), message, attrs...)
+	})
+}
+
+// level returns the level to log a request with the given status at.
+func (opts AccessLogOptions) level(status int) slog.Level {
+	if level, ok := opts.Levels[status/100]; ok {
+		return level
+	}
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// recorder records the status and number of bytes written to a response.
+type recorder struct {
+	http.ResponseWriter
+
+	status   int   // status code written, 0 if none yet
+	bytes    int64 // number of bytes written
+	hijacked bool  // was the connection hijacked?
+}
+
+// Unwrap returns the original writer, for use with [http.ResponseController].
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	// informational headers may be followed by a different status
+	if rec.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(data)
+	rec.bytes += int64(n)
+	return n, err //nolint:wrapcheck // error should be passed through
+}
+
+// wrap returns a writer recording to rec, implementing the optional interfaces of the original writer.
+func (rec *recorder) wrap() http.ResponseWriter {
+	_, canFlush := rec.ResponseWriter.(http.Flusher)
+	_, canHijack := rec.ResponseWriter.(http.Hijacker)
+	_, canReadFrom := rec.ResponseWriter.(io.ReaderFrom)
+
+	f, h, rf := recordFlusher{rec}, recordHijacker{rec}, recordReaderFrom{rec}
+	switch {
+	case canFlush && canHijack && canReadFrom:
+		return struct {
+			*recorder
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rec, f, h, rf}
+	case canFlush && canHijack:
+		return struct {
+			*recorder
+			http.Flusher
+			http.Hijacker
+		}{rec, f, h}
+	case canFlush && canReadFrom:
+		return struct {
+			*recorder
+			http.Flusher
+			io.ReaderFrom
+		}{rec, f, rf}
+	case canHijack && canReadFrom:
+		return struct {
+			*recorder
+			http.Hijacker
+			io.ReaderFrom
+		}{rec, h, rf}
+	case canFlush:
+		return struct {
+			*recorder
+			http.Flusher
+		}{rec, f}
+	case canHijack:
+		return struct {
+			*recorder
+			http.Hijacker
+		}{rec, h}
+	case canReadFrom:
+		return struct {
+			*recorder
+			io.ReaderFrom
+		}{rec, rf}
+	default:
+		return rec
+	}
+}
+
+type recordFlusher struct{ *recorder }
+
+func (rf recordFlusher) Flush() {
+	if rf.status == 0 {
+		rf.status = http.StatusOK
+	}
+	rf.ResponseWriter.(http.Flusher).Flush()
+}
+
+type recordHijacker struct{ *recorder }
+
+func (rh recordHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := rh.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		rh.hijacked = true
+	}
+	return conn, rw, err //nolint:wrapcheck // error should be passed through
+}
+
+type recordReaderFrom struct{ *recorder }
+
+func (rrf recordReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	if rrf.status == 0 {
+		rrf.status = http.StatusOK
+	}
+	n, err := rrf.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	rrf.bytes += n
+	return n, err //nolint:wrapcheck // error should be passed through
+}
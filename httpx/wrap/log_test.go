@@ -0,0 +1,211 @@
+//spellchecker:words wrap
+package wrap_test
+
+//spellchecker:words bufio context slog http httptest strings testing pkglib httpx wrap
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.tkw01536.de/pkglib/httpx"
+	"go.tkw01536.de/pkglib/httpx/mux"
+	"go.tkw01536.de/pkglib/httpx/wrap"
+)
+
+// newTestLogger returns a logger writing entries without time and duration to w.
+func newTestLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func ExampleAccessLog() {
+	var m mux.Mux
+	m.Handle("GET /users/{id}", httpx.Response{Body: []byte("a user")})
+
+	handler := wrap.AccessLog(&m, wrap.AccessLogOptions{
+		Logger: newTestLogger(os.Stdout),
+		Levels: map[int]slog.Level{4: slog.LevelDebug},
+	})
+
+	for _, path := range []string{"/users/42", "/missing"} {
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Request-Id", "id-"+strings.TrimPrefix(path, "/"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Output: level=INFO msg=request method=GET path=/users/42 status=200 bytes=6 remote=192.0.2.1:1234 route="GET /users/{id}" request_id=id-users/42
+	// level=DEBUG msg=request method=GET path=/missing status=404 bytes=19 remote=192.0.2.1:1234 request_id=id-missing
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			"implicit status",
+			func(w http.ResponseWriter, r *http.Request) {},
+			"level=INFO msg=request method=GET path=/ status=200 bytes=0 remote=192.0.2.1:1234\n",
+		},
+		{
+			"server error",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte("error"))
+			},
+			"level=ERROR msg=request method=GET path=/ status=500 bytes=5 remote=192.0.2.1:1234\n",
+		},
+		{
+			"informational status",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusNotFound)
+			},
+			"level=WARN msg=request method=GET path=/ status=404 bytes=0 remote=192.0.2.1:1234\n",
+		},
+		{
+			"read from",
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+			},
+			"level=INFO msg=request method=GET path=/ status=200 bytes=5 remote=192.0.2.1:1234\n",
+		},
+		{
+			"hijacked",
+			func(w http.ResponseWriter, r *http.Request) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					_ = conn.Close()
+				}
+			},
+			"level=INFO msg=request method=GET path=/ status=101 bytes=0 remote=192.0.2.1:1234\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buffer bytes.Buffer
+			handler := wrap.AccessLog(tt.handler, wrap.AccessLogOptions{Logger: newTestLogger(&buffer)})
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			handler.ServeHTTP(&fullWriter{ResponseRecorder: httptest.NewRecorder()}, req)
+
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("AccessLog() logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLog_route(t *testing.T) {
+	t.Parallel()
+
+	var inner mux.Mux
+	inner.Handle("GET /users/{id}", httpx.Response{Body: []byte("a user")})
+
+	var outer mux.Mux
+	outer.Mount("/api", &inner)
+
+	std := http.NewServeMux()
+	std.Handle("GET /users/{id}", httpx.Response{Body: []byte("a user")})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+		want    string
+	}{
+		{"mux", &inner, "/users/42", "GET /users/{id}"},
+		{"mux inside Time", wrap.Time(&inner), "/users/42", "GET /users/{id}"},
+		{"mounted mux", &outer, "/api/users/42", "GET /users/{id}"},
+		{"mounted mux inside Time", wrap.Time(&outer), "/api/users/42", "GET /users/{id}"},
+		{"http.ServeMux", std, "/users/42", "GET /users/{id}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buffer bytes.Buffer
+			handler := wrap.AccessLog(tt.handler, wrap.AccessLogOptions{Logger: newTestLogger(&buffer)})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(t.Context(), http.MethodGet, tt.path, nil))
+
+			if want := `route="` + tt.want + `"`; !strings.Contains(buffer.String(), want) {
+				t.Errorf("AccessLog() logged %q, want it to contain %q", buffer.String(), want)
+			}
+		})
+	}
+}
+
+func TestAccessLog_interfaces(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                        string
+		w                           http.ResponseWriter
+		flusher, hijacker, readFrom bool
+	}{
+		{"recorder", httptest.NewRecorder(), true, false, false},
+		{"full", &fullWriter{ResponseRecorder: httptest.NewRecorder()}, true, true, true},
+		{"plain", plainWriter{httptest.NewRecorder()}, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := wrap.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := w.(http.Flusher); ok != tt.flusher {
+					t.Errorf("writer implements http.Flusher = %t, want %t", ok, tt.flusher)
+				}
+				if _, ok := w.(http.Hijacker); ok != tt.hijacker {
+					t.Errorf("writer implements http.Hijacker = %t, want %t", ok, tt.hijacker)
+				}
+				if _, ok := w.(io.ReaderFrom); ok != tt.readFrom {
+					t.Errorf("writer implements io.ReaderFrom = %t, want %t", ok, tt.readFrom)
+				}
+			}), wrap.AccessLogOptions{Logger: newTestLogger(io.Discard)})
+
+			handler.ServeHTTP(tt.w, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+		})
+	}
+}
+
+// plainWriter implements only [http.ResponseWriter].
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// fullWriter implements [http.ResponseWriter], [http.Flusher], [http.Hijacker] and [io.ReaderFrom].
+type fullWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (fw *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func (fw *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(fw.ResponseRecorder, src)
+}
@@ -90,6 +90,12 @@ var (
 	maxPrefixSize = float64(len(humanPrefixes)) - 1
 )
 
+// HumanBytes formats a number of bytes as a human-readable string, such as "1.5 MB".
+// It uses decimal (SI) prefixes and is the format used by [Snapshot.BytesString].
+func HumanBytes(bytes int64) string {
+	return human(bytes)
+}
+
 func human(bytes int64) string {
 	var builder strings.Builder
 	_ = fmtHuman(&builder, float64(bytes)) // ignore return value, its always nil anyways